@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/config"
+)
+
+// migration is the outcome of migrating the configuration to a single file
+type migration struct {
+	File     string `json:"file"`
+	Sources  int    `json:"sources"`
+	Projects int    `json:"projects"`
+	// Active is whether the file is the one the configuration is now read from
+	Active bool `json:"active"`
+}
+
+// runConfig runs the config command named by the first argument. Global flags may also follow it.
+func runConfig(c *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config command, expected migrate or show")
+	}
+
+	name := args[0]
+	fs := flag.NewFlagSet("omnisync config "+name, flag.ContinueOnError)
+	c.register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	switch name {
+	case "migrate":
+		return runMigrate(c, fs.Args())
+	case "show":
+		return runShow(c, fs.Args())
+	default:
+		return fmt.Errorf("unknown config command `%s`, expected migrate or show", name)
+	}
+}
+
+// runMigrate converts the JSON files of the configuration into a single file, `omnisync.yaml` in the config
+// directory unless another file is given
+func runMigrate(c *cli, args []string) error {
+	file := path.Join(c.configDir, config.FileName+".yaml")
+	if len(args) > 0 {
+		file = args[0]
+	}
+
+	cfg, err := config.Migrate(c.configDir, file)
+	if err != nil {
+		return err
+	}
+
+	m := migration{File: file, Sources: len(cfg.Sources), Projects: len(cfg.Projects)}
+	if active, err := config.Find(c.configDir); err == nil && active == file {
+		m.Active = true
+	}
+
+	return c.print(m, func(w io.Writer) {
+		fmt.Fprintf(w, "Migrated %d sources and %d projects to %s.\n", m.Sources, m.Projects, m.File)
+		if m.Active {
+			fmt.Fprintf(w, "The JSON files in %s are no longer read and can be removed.\n", c.configDir)
+		}
+	})
+}
+
+// runShow prints the configuration that is used, as YAML or as JSON with `--output json`
+func runShow(c *cli, args []string) error {
+	cfg, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	format := config.FormatYAML
+	if c.output == "json" {
+		format = config.FormatJSON
+	}
+
+	data, err := cfg.Encode(format)
+	if err != nil {
+		return fmt.Errorf("failed to print the configuration: %w", err)
+	}
+
+	_, err = c.stdout.Write(data)
+	return err
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/config"
+	"github.com/trevorpiltch/omnifocus-sync/internal/daemon"
+	"github.com/trevorpiltch/omnifocus-sync/internal/webhook"
+)
+
+// runDaemon syncs every source on its schedule until SIGTERM or an interrupt, or runs the daemon command named by
+// the first argument
+func runDaemon(c *cli, args []string) error {
+	if len(args) > 0 {
+		if args[0] != "install" {
+			return fmt.Errorf("unknown daemon command `%s`, expected install", args[0])
+		}
+
+		return runInstall(c, args[1:])
+	}
+
+	log.Printf("[main] Starting OmniSync daemon version: %s", version)
+
+	cfg, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	jobs, err := daemonJobs(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	d := &daemon.Daemon{Jobs: jobs, Sync: c.syncTargets, Reload: c.reloadJobs}
+
+	if cfg.Settings.Listen != "" {
+		server, err := listen(cfg, d)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+	}
+
+	d.Run(ctx)
+
+	return nil
+}
+
+// daemonJobs returns a job for every source of the configuration, on its schedule
+func daemonJobs(cfg config.Config) ([]daemon.Job, error) {
+	var jobs []daemon.Job
+	for _, src := range cfg.Sources {
+		sched := src.GetSchedule(cfg.Settings.Schedule)
+		if err := sched.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid schedule of source %s: %w", src.Name, err)
+		}
+
+		jobs = append(jobs, daemon.Job{Name: src.Name, Schedule: sched})
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no sources to sync")
+	}
+
+	return jobs, nil
+}
+
+// reloadJobs loads the configuration again and returns its jobs
+func (c *cli) reloadJobs() ([]daemon.Job, error) {
+	cfg, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return daemonJobs(cfg)
+}
+
+// listen starts accepting the webhooks of the sources on the address of the settings, triggering syncs of the daemon
+func listen(cfg config.Config, d *daemon.Daemon) (*http.Server, error) {
+	for _, src := range cfg.Sources {
+		if err := src.ValidateWebhook(); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("tcp", cfg.Settings.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept webhooks: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(webhook.Prefix, &webhook.Handler{Sources: cfg.Sources, Trigger: d.Trigger})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[webhook] Stopped accepting webhooks: %s", err)
+		}
+	}()
+
+	for _, src := range cfg.Sources {
+		if src.Webhook != nil {
+			log.Printf("[webhook] Accepting the webhooks of %s at http://%s%s", src.Name, ln.Addr(), webhook.Path(src.Name))
+		}
+	}
+
+	return server, nil
+}
+
+// syncTargets syncs the targets as one run and returns the error of each source that failed. The configuration
+// is loaded again first, so changes to it apply from the next cycle without a restart, and targets whose source
+// was removed from it are skipped.
+func (c *cli) syncTargets(targets []daemon.Target) map[string]error {
+	errs := map[string]error{}
+	fail := func(err error) map[string]error {
+		for _, target := range targets {
+			errs[target.Name] = err
+		}
+
+		return errs
+	}
+
+	cfg, err := c.load()
+	if err != nil {
+		return fail(err)
+	}
+
+	configured := map[string]bool{}
+	for _, src := range cfg.Sources {
+		configured[src.Name] = true
+	}
+
+	selected := *c
+	selected.sources = nil
+	records := map[string][]map[string]interface{}{}
+	for _, target := range targets {
+		if !configured[target.Name] {
+			log.Printf("[daemon] Skipping %s, it is no longer configured", target.Name)
+			continue
+		}
+
+		selected.sources = append(selected.sources, target.Name)
+		if target.Records == nil {
+			continue
+		}
+
+		urls := make([]string, 0, len(target.Records))
+		for url := range target.Records {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+
+		for _, url := range urls {
+			records[target.Name] = append(records[target.Name], target.Records[url])
+		}
+	}
+
+	if len(selected.sources) == 0 {
+		return errs
+	}
+
+	cfg.Sources, err = selected.selectSources(cfg.Sources)
+	if err != nil {
+		return fail(err)
+	}
+
+	results, err := c.run(cfg, records, false)
+	if results == nil {
+		return fail(err)
+	}
+
+	for _, result := range results {
+		if result.Error != "" {
+			errs[result.Source] = errors.New(result.Error)
+		}
+	}
+
+	if err := c.print(results, func(w io.Writer) { printResults(w, results) }); err != nil {
+		log.Printf("[main] Failed to print the results: %s", err)
+	}
+
+	return errs
+}
+
+// runInstall writes a launchd agent or systemd user unit that runs the daemon at login, or prints it with `--print`
+func runInstall(c *cli, args []string) error {
+	var printOnly bool
+	initName := string(daemon.InitSystemd)
+	if runtime.GOOS == "darwin" {
+		initName = string(daemon.InitLaunchd)
+	}
+
+	fs := flag.NewFlagSet("omnisync daemon install", flag.ContinueOnError)
+	c.register(fs)
+	fs.BoolVar(&printOnly, "print", false, "print the service file instead of writing it")
+	fs.StringVar(&initName, "init", initName, "the `system` that starts the daemon: launchd or systemd, defaults to the one of this OS")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	system, err := daemon.ParseInit(initName)
+	if err != nil {
+		return err
+	}
+
+	service, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	unit := system.Render(service)
+	if printOnly {
+		_, err := io.WriteString(c.stdout, unit)
+		return err
+	}
+
+	file := system.Path(c.home)
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path.Dir(file), err)
+	}
+
+	if err := os.WriteFile(file, []byte(unit), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+
+	fmt.Fprintf(c.stdout, "Installed %s. Start the daemon with:\n  %s\n", file, system.Enable(c.home))
+	return nil
+}
+
+// service returns how the daemon is started with the global flags of this invocation
+func (c *cli) service() (daemon.Service, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return daemon.Service{}, fmt.Errorf("failed to find the omnisync binary: %w", err)
+	}
+
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return daemon.Service{}, fmt.Errorf("failed to find the omnisync binary: %w", err)
+	}
+
+	configDir, err := filepath.Abs(c.configDir)
+	if err != nil {
+		return daemon.Service{}, fmt.Errorf("failed to find the config directory: %w", err)
+	}
+
+	args := []string{"--config-dir", configDir}
+	if c.profile != "" {
+		args = append(args, "--profile", c.profile)
+	}
+	for _, name := range c.sources {
+		args = append(args, "--source", name)
+	}
+	for _, name := range c.projects {
+		args = append(args, "--project", name)
+	}
+	args = append(args, "daemon")
+
+	return daemon.Service{
+		Executable: exe,
+		Args:       args,
+		LogFile:    path.Join(c.home, "Library", "Logs", "omnisync.log"),
+	}, nil
+}
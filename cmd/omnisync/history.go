@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/audit"
+)
+
+// runHistory prints every run in the audit log, or the events of the run with the given ID
+func runHistory(c *cli, args []string) error {
+	if len(args) > 0 {
+		return c.historyRun(args[0])
+	}
+
+	runs, err := audit.Runs(c.stateDir())
+	if err != nil {
+		return err
+	}
+
+	if runs == nil {
+		runs = []audit.Run{}
+	}
+
+	return c.print(runs, func(w io.Writer) {
+		if len(runs) == 0 {
+			fmt.Fprintln(w, "No runs yet.")
+			return
+		}
+
+		fmt.Fprintln(w, "RUN\tSTARTED\tDURATION\tSOURCES\tAPPLIED\tFAILED\tSKIPPED\tSTATUS")
+		for _, r := range runs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+				r.ID,
+				r.StartedAt.Local().Format(time.DateTime),
+				duration(r),
+				r.Sources,
+				r.Applied,
+				r.Failed,
+				r.Skipped,
+				runStatusText(r),
+			)
+		}
+	})
+}
+
+// historyRun prints the events of the run with the given ID
+func (c *cli) historyRun(id string) error {
+	events, err := audit.Events(c.stateDir(), id)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return fmt.Errorf("run %s does not exist", id)
+	}
+
+	return c.print(events, func(w io.Writer) {
+		for _, e := range events {
+			at := e.Time.Local().Format(time.TimeOnly)
+			switch e.Type {
+			case audit.EventStart:
+				fmt.Fprintf(w, "%s\tstart\tversion %s, config %s\n", at, e.Version, e.ConfigHash)
+			case audit.EventFetch:
+				if e.Error != "" {
+					fmt.Fprintf(w, "%s\tfetch\t%s: %s\n", at, e.Source, e.Error)
+				} else {
+					fmt.Fprintf(w, "%s\tfetch\t%s: %d items\n", at, e.Source, e.Items)
+				}
+			case audit.EventOperation:
+				fmt.Fprintf(w, "%s\t%s\t%s: %s %s", at, e.Outcome, e.Source, e.Action, e.Name)
+				if e.ExternalID != "" {
+					fmt.Fprintf(w, " (%s)", e.ExternalID)
+				}
+				if e.Error != "" {
+					fmt.Fprintf(w, ": %s", e.Error)
+				}
+				fmt.Fprintln(w)
+			case audit.EventEnd:
+				if e.Error != "" {
+					fmt.Fprintf(w, "%s\tend\t%s\n", at, e.Error)
+				} else {
+					fmt.Fprintf(w, "%s\tend\t\n", at)
+				}
+			}
+		}
+	})
+}
+
+// duration returns how long the run took, or a dash when it never ended
+func duration(r audit.Run) string {
+	if r.EndedAt.IsZero() {
+		return "-"
+	}
+
+	return r.EndedAt.Sub(r.StartedAt).Round(time.Millisecond).String()
+}
+
+// runStatusText returns how the run ended
+func runStatusText(r audit.Run) string {
+	switch {
+	case r.EndedAt.IsZero():
+		return "interrupted"
+	case r.Error != "":
+		return "failed"
+	default:
+		return "ok"
+	}
+}
@@ -1,101 +1,363 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
-
-	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
-	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
-	"github.com/trevorpiltch/omnifocus-sync/internal/project"
-	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+	"strings"
+	"text/tabwriter"
 )
 
 const version = "1.0.0"
 
-func main() {
-	log.Printf("[main] Starting OmniSync version: %s", version)
+// globals are the flags every command takes
+type globals struct {
+	configDir string
+	profile   string
+	sources   list
+	projects  list
+	verbose   bool
+	quiet     bool
+	output    string
+}
+
+// list is a flag that can be given more than once
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// command is a subcommand of omnisync
+type command struct {
+	name string
+	// args describes the arguments of the command, e.g. `[run-id]`
+	args string
+	// summary is shown in the list of commands
+	summary string
+	// help describes the command in full
+	help string
+	// flags registers the flags of the command
+	flags func(fs *flag.FlagSet)
+	run   func(c *cli, args []string) error
+}
+
+// cli holds what every command needs
+type cli struct {
+	globals
+	home   string
+	stdout io.Writer
+	// command is the name of the command that runs
+	command string
+}
+
+// commands are the subcommands of omnisync. The first one runs when none is given.
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:    "sync",
+			summary: "bring OmniFocus in line with the sources",
+			help: `Fetches the items of every source and adds, completes, reopens, updates and moves their
+tasks in OmniFocus. Every change is journaled so the run can be undone, and logged in the
+audit log. This is the command that runs when none is given.
+
+Only one omnisync syncs or undoes a run at a time. Another one exits straight away, or waits
+for it to finish with --wait.`,
+			flags: syncCommandFlags.register,
+			run:   runSync,
+		},
+		{
+			name:    "plan",
+			summary: "show the changes a sync would make without making them",
+			help: `Fetches the items of every source and compares them with OmniFocus like sync does, but
+only prints the changes instead of making them. Nothing is journaled or audited and the
+snapshots of incremental sources are left alone.`,
+			flags: syncCommandFlags.register,
+			run:   runPlan,
+		},
+		{
+			name:    "daemon",
+			args:    "[install [--print] [--init launchd|systemd]]",
+			summary: "keep syncing the sources, each on its schedule",
+			help: `Runs until SIGTERM or an interrupt, syncing every source on its Schedule: an interval or a
+cron expression, with jitter, waiting longer after every failure in a row. Sources that are
+due together are synced in one run, and a source that comes due while a run is still going
+skips to its next time. The configuration, schedules included, is read again before every run.
+
+With Listen set in the settings, the webhooks of the sources are accepted as well, and the
+item a verified delivery reports is synced in the next run.
+
+install writes a launchd agent on macOS, or a systemd user unit elsewhere, that starts the
+daemon at login with the global flags given to install. --print prints it instead, and
+--init picks the init system.`,
+			flags: syncCommandFlags.register,
+			run:   runDaemon,
+		},
+		{
+			name:    "validate",
+			summary: "check the configuration",
+			help: `Loads the configuration and reports every problem that would stop a source from being
+synced. Exits with a non zero status when the configuration is invalid.`,
+			run: runValidate,
+		},
+		{
+			name:    "config",
+			args:    "migrate [file] | show",
+			summary: "manage the configuration",
+			help: `migrate converts projects.json, sources.json and settings.json into a single configuration
+file, omnisync.yaml in the config directory by default. The format is picked by the extension
+of the file: .yaml, .yml, .toml or .json. The file is only written when it holds exactly the
+same configuration, and is read instead of the JSON files from then on.
+
+show prints the configuration that is used, with the profile selected by --profile applied
+on top of the profiles it extends.`,
+			run: runConfig,
+		},
+		{
+			name:    "status",
+			summary: "show what is synced for every source",
+			help: `Shows, for every source, how many items have a task in OmniFocus and when they were last
+synced, and how the latest run went.`,
+			run: runStatus,
+		},
+		{
+			name:    "history",
+			args:    "[run-id]",
+			summary: "list the runs, or show what one of them did",
+			help: `Lists every run in the audit log with how many changes it applied, failed or skipped.
+With a run ID, shows what the run fetched from each source and every change it made.`,
+			run: runHistory,
+		},
+		{
+			name:    "undo",
+			args:    "[run-id]",
+			summary: "reverse the changes of a run",
+			help: `Reverses the changes of the latest run, or of the run with the given ID: added tasks are
+deleted, closed tasks are reopened, deleted tasks are added again and changed tasks get
+their previous state back.`,
+			flags: syncCommandFlags.registerWait,
+			run:   runUndo,
+		},
+		{
+			name:    "version",
+			summary: "print the version",
+			help:    `Prints the version of omnisync.`,
+			run:     runVersion,
+		},
+	}
+}
 
+func main() {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal("Failed to find user home directory.")
 	}
 
-	configDir := path.Join(home, ".config", "omnisync")
+	os.Exit(newCLI(home).main(os.Args[1:]))
+}
 
-	projects, err := project.LoadProjects(configDir)
-	if err != nil {
-		log.Fatal(err)
+// newCLI returns the command line with the default global flags for the given home directory
+func newCLI(home string) *cli {
+	return &cli{
+		globals: globals{
+			configDir: path.Join(home, ".config", "omnisync"),
+			profile:   os.Getenv("OMNISYNC_PROFILE"),
+			output:    "text",
+		},
+		home:   home,
+		stdout: os.Stdout,
 	}
+}
 
-	sources, err := source.LoadSources(configDir)
-	if err != nil {
-		log.Fatal(err)
+// main runs the command given by the arguments and returns the exit status
+func (c *cli) main(args []string) int {
+	fs := flag.NewFlagSet("omnisync", flag.ContinueOnError)
+	c.register(fs)
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		return exitStatus(err)
+	}
+
+	name, args := commands[0].name, fs.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
 	}
 
-	for _, source := range sources {
-		log.Printf("[main] **** %s ****", source.Name)
+	if name == "help" {
+		return c.help(args)
+	}
 
-		currentState, err := omnifocus.GetAllItems(projects, source.Tags)
-		if err != nil {
-			log.Fatal(err)
-		}
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "omnisync: unknown command `%s`\n\n", name)
+		usage(fs)
+		return 2
+	}
 
-		log.Printf("[main] Current state: %d\n", len(currentState))
+	fs = cmd.flagSet(c)
+	if err := fs.Parse(args); err != nil {
+		return exitStatus(err)
+	}
 
-		items, err := source.GetItems()
-		if err != nil {
-			log.Fatal(err)
-		}
+	if err := c.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "omnisync: %s\n", err)
+		return 2
+	}
+
+	if c.quiet {
+		log.SetOutput(io.Discard)
+	}
 
-		log.Printf("[main] Desired state: %d\n", len(items))
+	c.command = cmd.name
+	if err := cmd.run(c, fs.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "omnisync: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// register adds the global flags to the flag set. Their defaults are the values parsed so far, so flags given
+// before the command are kept when the flags after it are parsed.
+func (c *cli) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configDir, "config-dir", c.configDir, "the directory holding the configuration")
+	fs.StringVar(&c.profile, "profile", c.profile, "use the configuration profile with this `name`, defaults to $OMNISYNC_PROFILE")
+	fs.Var(&c.sources, "source", "only sync the source with this `name`, may be repeated")
+	fs.Var(&c.projects, "project", "only sync the items routed to this OmniFocus `project`, Inbox for the Inbox, may be repeated")
+	fs.BoolVar(&c.verbose, "verbose", c.verbose, "log more detail, such as which project rule matched each item")
+	fs.BoolVar(&c.quiet, "quiet", c.quiet, "only print errors and results")
+	fs.StringVar(&c.output, "output", c.output, "the `format` of results: text or json")
+}
+
+// validate returns an error for flags that can't be used together or have invalid values
+func (c *cli) validate() error {
+	if c.verbose && c.quiet {
+		return fmt.Errorf("--verbose and --quiet can't be used together")
+	}
+
+	if c.output != "text" && c.output != "json" {
+		return fmt.Errorf("unknown output `%s`, expected text or json", c.output)
+	}
+
+	return nil
+}
+
+// stateDir returns the directory OmniSync keeps its state between runs in, following the XDG base directory spec
+func (c *cli) stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return path.Join(dir, "omnisync")
+	}
+
+	return path.Join(c.home, ".local", "state", "omnisync")
+}
+
+// print writes the value as JSON with `--output json`, or as text using the given function otherwise
+func (c *cli) print(v interface{}, text func(w io.Writer)) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	text(w)
+	return w.Flush()
+}
+
+// help prints the help of the named command, or the list of commands
+func (c *cli) help(args []string) int {
+	if len(args) == 0 {
+		fs := flag.NewFlagSet("omnisync", flag.ContinueOnError)
+		c.register(fs)
+		fs.SetOutput(c.stdout)
+		usage(fs)
+		return 0
+	}
+
+	cmd, ok := lookup(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "omnisync: unknown command `%s`\n", args[0])
+		return 2
+	}
 
-		for i, item := range items {
-			projectName, _ := project.ProjectFor(item.Note, projects)
-			items[i].ProjectName = projectName.OFName
+	fs := cmd.flagSet(c)
+	fs.SetOutput(c.stdout)
+	fs.Usage()
+	return 0
+}
+
+// lookup returns the command with the given name
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
+	}
+
+	return command{}, false
+}
+
+// flagSet returns the flags of the command. Global flags may also follow the command.
+func (cmd command) flagSet(c *cli) *flag.FlagSet {
+	fs := flag.NewFlagSet("omnisync "+cmd.name, flag.ContinueOnError)
+	c.register(fs)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
 
-		d := delta.Delta(toSetSource(items), toSet(currentState))
-
-		log.Printf("[main] Found %d changes to apply", len(d))
-		for _, d := range d {
-			if d.Type == delta.Add {
-				err := omnifocus.AddItem(*(d.Item.(*omnifocus.NewOmniFocusItem)))
-				if err != nil {
-					log.Fatal(err)
-				}
-			} else if d.Type == delta.Remove {
-				err := omnifocus.CompleteItem(*(d.Item.(*omnifocus.Item)))
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: omnisync %s [flags]", cmd.name)
+		if cmd.args != "" {
+			fmt.Fprintf(w, " %s", cmd.args)
 		}
+		fmt.Fprintf(w, "\n\n%s\n\nFlags:\n", cmd.help)
+		fs.PrintDefaults()
 	}
+
+	return fs
 }
 
-func toSet(l []omnifocus.Item) map[delta.Keyed]struct{} {
-	r := map[delta.Keyed]struct{}{}
-	for _, i := range l {
-		// need to clone because range reuses `i` for each item!
-		r[&omnifocus.Item{
-			ID:   i.ID,
-			Name: i.Name,
-		}] = struct{}{}
+// exitStatus returns the exit status for an error parsing flags. Asking for help isn't an error.
+func exitStatus(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
-	return r
+
+	return 2
 }
 
-func toSetSource(l []omnifocus.NewOmniFocusItem) map[delta.Keyed]struct{} {
-	r := map[delta.Keyed]struct{}{}
-	for _, i := range l {
-		// need to clone because range reuses `i` for each item!
-		r[&omnifocus.NewOmniFocusItem{
-			Name:        i.Name,
-			ProjectName: i.ProjectName,
-			Tags:        i.Tags,
-			Note:        i.Note,
-		}] = struct{}{}
+// usage prints the commands and global flags
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: omnisync [flags] <command> [arguments]\n\nCommands:\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
 	}
-	return r
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nRun `omnisync help <command>` for more about a command.\n\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// runVersion prints the version
+func runVersion(c *cli, args []string) error {
+	return c.print(map[string]string{"version": version}, func(w io.Writer) {
+		fmt.Fprintf(w, "omnisync %s\n", version)
+	})
 }
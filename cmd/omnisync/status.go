@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/audit"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
+)
+
+// sourceStatus is what is synced for a source
+type sourceStatus struct {
+	Source   string     `json:"source"`
+	Tracked  int        `json:"tracked"`
+	SyncedAt *time.Time `json:"syncedAt,omitempty"`
+}
+
+// status is what is synced for every source, and how the latest run went
+type status struct {
+	Sources []sourceStatus `json:"sources"`
+	LastRun *audit.Run     `json:"lastRun,omitempty"`
+}
+
+// runStatus prints what is synced for every source
+func runStatus(c *cli, args []string) error {
+	cfg, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	store, err := state.Load(c.stateDir())
+	if err != nil {
+		return err
+	}
+
+	runs, err := audit.Runs(c.stateDir())
+	if err != nil {
+		return err
+	}
+
+	s := status{Sources: []sourceStatus{}}
+	for _, src := range cfg.Sources {
+		st := sourceStatus{Source: src.Name}
+		for _, r := range store.Sources[src.Name] {
+			st.Tracked++
+			if st.SyncedAt == nil || r.SyncedAt.After(*st.SyncedAt) {
+				syncedAt := r.SyncedAt
+				st.SyncedAt = &syncedAt
+			}
+		}
+
+		s.Sources = append(s.Sources, st)
+	}
+
+	if len(runs) > 0 {
+		s.LastRun = &runs[len(runs)-1]
+	}
+
+	return c.print(s, func(w io.Writer) {
+		fmt.Fprintln(w, "SOURCE\tTASKS\tLAST SYNCED")
+		for _, st := range s.Sources {
+			synced := "never"
+			if st.SyncedAt != nil {
+				synced = st.SyncedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", st.Source, st.Tracked, synced)
+		}
+
+		if s.LastRun != nil {
+			fmt.Fprintf(w, "\nLast run %s at %s: %s\n", s.LastRun.ID, s.LastRun.StartedAt.Local().Format(time.DateTime), runStatusText(*s.LastRun))
+		}
+	})
+}
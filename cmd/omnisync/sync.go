@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/audit"
+	"github.com/trevorpiltch/omnifocus-sync/internal/config"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+	"github.com/trevorpiltch/omnifocus-sync/internal/journal"
+	"github.com/trevorpiltch/omnifocus-sync/internal/lock"
+	"github.com/trevorpiltch/omnifocus-sync/internal/runner"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
+)
+
+// syncFlags are the flags of the commands that sync
+type syncFlags struct {
+	force              bool
+	maxComplete        int
+	maxCompletePercent float64
+	noCache            bool
+	full               bool
+	explain            bool
+	wait               time.Duration
+}
+
+// syncCommandFlags holds the parsed flags of the sync and plan commands
+var syncCommandFlags = &syncFlags{maxCompletePercent: 50}
+
+// register adds the flags to the flag set
+func (f *syncFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.force, "force", f.force, "complete tasks even when a source exceeds its safety limits")
+	fs.IntVar(&f.maxComplete, "max-complete", f.maxComplete, "the maximum number of tasks a source may complete in one run, 0 for no limit")
+	fs.Float64Var(&f.maxCompletePercent, "max-complete-percent", f.maxCompletePercent, "the maximum percentage of a source's tasks that may be completed in one run, 0 for no limit")
+	fs.BoolVar(&f.noCache, "no-cache", f.noCache, "download every source in full instead of revalidating cached responses")
+	fs.BoolVar(&f.full, "full", f.full, "fetch all items of incremental sources instead of only the ones updated since the last run")
+	fs.BoolVar(&f.explain, "explain", f.explain, "log which project rule matched each item")
+	f.registerWait(fs)
+}
+
+// registerWait adds the flag of the commands that take the lock to the flag set
+func (f *syncFlags) registerWait(fs *flag.FlagSet) {
+	fs.DurationVar(&f.wait, "wait", f.wait, "wait up to this `duration` for another running omnisync to finish, instead of exiting straight away")
+}
+
+// lock takes the lock of the state directory, so that no other omnisync changes OmniFocus at the same time
+func (c *cli) lock() (*lock.Lock, error) {
+	l, err := lock.Acquire(c.stateDir(), c.command, syncCommandFlags.wait)
+	var locked *lock.LockedError
+	if errors.As(err, &locked) {
+		return nil, fmt.Errorf("%w. Pass --wait <duration> to wait for it to finish", err)
+	}
+
+	return l, err
+}
+
+// load returns the configuration of the profile selected by `--profile`, with only the sources selected by `--source`
+func (c *cli) load() (config.Config, error) {
+	cfg, err := config.Load(c.configDir)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cfg, err = cfg.Resolve(c.profile)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cfg.Sources, err = c.selectSources(cfg.Sources)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// selectSources returns the sources named by `--source`, or all of them without the flag
+func (c *cli) selectSources(sources []source.Source) ([]source.Source, error) {
+	if len(c.sources) == 0 {
+		return sources, nil
+	}
+
+	byName := map[string]source.Source{}
+	for _, src := range sources {
+		byName[src.Name] = src
+	}
+
+	var selected []source.Source
+	for _, name := range c.sources {
+		src, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown source `%s`", name)
+		}
+
+		selected = append(selected, src)
+	}
+
+	return selected, nil
+}
+
+// runSync brings OmniFocus in line with the sources
+func runSync(c *cli, args []string) error {
+	return c.sync(false)
+}
+
+// runPlan prints the changes a sync would make
+func runPlan(c *cli, args []string) error {
+	return c.sync(true)
+}
+
+// sync syncs every selected source, or only plans the changes in a dry run, and prints the results
+func (c *cli) sync(dryRun bool) error {
+	log.Printf("[main] Starting OmniSync version: %s", version)
+
+	cfg, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	results, runErr := c.run(cfg, nil, dryRun)
+	if results == nil {
+		return runErr
+	}
+
+	if err := c.print(results, func(w io.Writer) { printResults(w, results) }); err != nil {
+		return err
+	}
+
+	return runErr
+}
+
+// run syncs the sources of the configuration, or only plans the changes in a dry run, as one audited run.
+// Sources with records only sync the items of those raw records. The results are nil when the run couldn't start.
+func (c *cli) run(cfg config.Config, records map[string][]map[string]interface{}, dryRun bool) ([]runner.Result, error) {
+	f := syncCommandFlags
+
+	opts := source.Options{
+		StateDir: c.stateDir(),
+		Full:     f.full,
+	}
+
+	if !f.noCache {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+		}
+
+		opts.Cache = source.NewCache(path.Join(cacheDir, "omnisync"))
+	}
+
+	// The state is only read once the lock is held, so it holds the changes of the run that held it before
+	if !dryRun {
+		l, err := c.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer l.Release()
+	}
+
+	store, err := state.Load(opts.StateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	j := journal.New(opts.StateDir, time.Now())
+
+	r := &runner.Runner{
+		Projects: cfg.Projects,
+		Settings: cfg.Settings,
+		Fetch:    opts,
+		State:    store,
+		Journal:  j,
+		Audit:    audit.Open(opts.StateDir, j.ID),
+		Options: runner.Options{
+			Force:    f.force,
+			Explain:  f.explain || c.verbose,
+			DryRun:   dryRun,
+			Projects: c.projects,
+			Limits: delta.Limits{
+				MaxRemove:        f.maxComplete,
+				MaxRemovePercent: f.maxCompletePercent,
+			},
+		},
+	}
+
+	if !dryRun {
+		err := r.Audit.Start(version, audit.ConfigHash(config.Files(c.configDir)...))
+		if err != nil {
+			log.Printf("[main] Failed to audit the run: %s", err)
+		}
+	}
+
+	failed := 0
+	results := []runner.Result{}
+	for _, src := range cfg.Sources {
+		log.Printf("[main] **** %s ****", src.Name)
+
+		var result runner.Result
+		if targeted, ok := records[src.Name]; ok {
+			result, err = r.SyncRecords(src, targeted)
+		} else {
+			result, err = r.SyncSource(src)
+		}
+		if err != nil {
+			log.Printf("[main] %s", err)
+			failed++
+		}
+
+		results = append(results, result)
+	}
+
+	var runErr error
+	if failed > 0 {
+		runErr = fmt.Errorf("%d of %d sources failed", failed, len(cfg.Sources))
+	}
+
+	if !dryRun {
+		if err := r.Audit.End(runErr); err != nil {
+			log.Printf("[main] Failed to audit the run: %s", err)
+		}
+	}
+
+	return results, runErr
+}
+
+// printResults prints the changes of every source
+func printResults(w io.Writer, results []runner.Result) {
+	for _, result := range results {
+		fmt.Fprintf(w, "%s: %d items, %d changes\n", result.Source, result.Fetched, len(result.Changes))
+		for _, change := range result.Changes {
+			fmt.Fprintf(w, "  %s\t%s\t%s", change.Outcome, change.Action, change.Name)
+			if change.Error != "" {
+				fmt.Fprintf(w, ": %s", change.Error)
+			}
+			fmt.Fprintln(w)
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", result.Error)
+		}
+	}
+}
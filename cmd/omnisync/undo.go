@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/journal"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
+)
+
+// runUndo reverses the changes of the run with the given ID, or of the latest run without one
+func runUndo(c *cli, args []string) error {
+	id := ""
+	if len(args) > 0 {
+		id = args[0]
+	}
+
+	l, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return undo(c.stateDir(), id)
+}
+
+// undo reverses the changes of the run with the given ID, or of the latest run when it is empty. Added
+// tasks are deleted, deleted tasks are added again and every other task is restored to its previous state.
+func undo(dir, id string) error {
+	j, err := journal.Load(dir, id)
+	if err != nil {
+		return err
+	}
+
+	store, err := state.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[main] Undoing %d changes of run %s", len(j.Entries), j.ID)
+	err = j.Undo(time.Now(), func(e journal.Entry) error {
+		switch {
+		case e.Action == journal.ActionAdd:
+			if err := omnifocus.DeleteItem(omnifocus.Item{ID: e.TaskID, Name: e.Name}); err != nil {
+				// The task still exists, so its record is kept for the next run to match it
+				return err
+			}
+
+			// The item is added again by the next run, rather than being treated as removed in OmniFocus
+			store.Delete(e.Source, e.ExternalID)
+			return nil
+		case e.Previous == nil:
+			return fmt.Errorf("no previous state to restore")
+		case e.Action == journal.ActionDelete:
+			p := e.Previous
+			added, err := omnifocus.AddItem(omnifocus.NewOmniFocusItem{
+				ProjectName: p.ProjectName,
+				Inbox:       p.Inbox,
+				Name:        p.Name,
+				Tags:        p.Tags,
+				Note:        p.Note,
+				DueDateMS:   p.DueDateMS,
+			})
+			if err != nil {
+				return err
+			}
+
+			// The task added again has a new ID, which the next run has to match with its item
+			if e.ExternalID != "" {
+				r, ok := store.Get(e.Source, e.ExternalID)
+				if !ok {
+					r = state.Record{ExternalID: e.ExternalID, Name: p.Name}
+				}
+				r.TaskID = added.ID
+				store.Put(e.Source, r)
+			}
+
+			return nil
+		default:
+			return omnifocus.RestoreItem(*e.Previous)
+		}
+	})
+
+	if saveErr := store.Save(); saveErr != nil {
+		log.Printf("[main] Failed to save the sync state: %s", saveErr)
+	}
+
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/config"
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+// report is the outcome of validating the configuration
+type report struct {
+	Valid       bool              `json:"valid"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics"`
+}
+
+// runValidate checks the configuration file, or every JSON file of the configuration, and fails when any of
+// them has errors
+func runValidate(c *cli, args []string) error {
+	rep := report{Diagnostics: []diag.Diagnostic{}}
+
+	file, err := config.Find(c.configDir)
+	switch {
+	case err != nil:
+		rep.Diagnostics = append(rep.Diagnostics, diag.Diagnostic{File: c.configDir, Severity: diag.SeverityError, Message: err.Error()})
+	case file != "":
+		rep.Diagnostics = append(rep.Diagnostics, config.Check(file)...)
+	default:
+		var projects []project.Project
+		var sources []source.Source
+		var settings config.Settings
+
+		rep.Diagnostics = append(rep.Diagnostics, checkFile(path.Join(c.configDir, "projects.json"), true, &projects, func() []diag.Problem {
+			return project.Check(projects)
+		})...)
+		rep.Diagnostics = append(rep.Diagnostics, checkFile(path.Join(c.configDir, "sources.json"), true, &sources, func() []diag.Problem {
+			return source.Check(sources)
+		})...)
+		rep.Diagnostics = append(rep.Diagnostics, checkFile(path.Join(c.configDir, "settings.json"), false, &settings, func() []diag.Problem {
+			return settings.Check()
+		})...)
+	}
+
+	rep.Valid = !diag.HasErrors(rep.Diagnostics)
+
+	errs := 0
+	for _, d := range rep.Diagnostics {
+		if d.Severity == diag.SeverityError {
+			errs++
+		}
+	}
+
+	err = c.print(rep, func(w io.Writer) {
+		for _, d := range rep.Diagnostics {
+			fmt.Fprintln(w, d.Error())
+		}
+
+		if rep.Valid {
+			fmt.Fprintf(w, "The configuration in %s is valid.\n", c.configDir)
+		} else {
+			fmt.Fprintf(w, "Found %d errors and %d warnings.\n", errs, len(rep.Diagnostics)-errs)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !rep.Valid {
+		return fmt.Errorf("the configuration has %d errors", errs)
+	}
+
+	return nil
+}
+
+// checkFile decodes the configuration file into the value and returns its problems. The check runs once
+// the file decodes, and optional files that don't exist have no problems.
+func checkFile(file string, required bool, v interface{}, check func() []diag.Problem) []diag.Diagnostic {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) && !required {
+		return nil
+	} else if err != nil {
+		return []diag.Diagnostic{{File: file, Severity: diag.SeverityError, Message: fmt.Sprintf("failed to read: %s", err)}}
+	}
+
+	if err := diag.Decode(file, data, v); err != nil {
+		var d diag.Diagnostic
+		if !errors.As(err, &d) {
+			d = diag.Diagnostic{File: file, Severity: diag.SeverityError, Message: err.Error()}
+		}
+
+		return []diag.Diagnostic{d}
+	}
+
+	diags := diag.Unknown(file, data, v)
+	return append(diags, diag.Locate(file, data, check())...)
+}
@@ -21,10 +21,12 @@ package omnifocus
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // ItemsForQuery returns a list of items from Omnifocus that
@@ -47,6 +49,27 @@ func ItemsForQuery(q ItemQuery) ([]Item, error) {
 	return items, nil
 }
 
+// SearchItemsForQuery returns a list of items from anywhere in Omnifocus
+// that have all the tags of the passed query, or without tags whose note starts
+// with one of its notes.
+func SearchItemsForQuery(q ItemQuery) ([]Item, error) {
+	jsCode, _ := jxa.ReadFile("jxa/ofsearchtasks.js")
+	args, _ := json.Marshal(q)
+
+	out, err := executeScript(jsCode, args)
+	if err != nil {
+		return []Item{}, err
+	}
+
+	items := []Item{}
+	err = json.Unmarshal(out, &items)
+	if err != nil {
+		return []Item{}, err
+	}
+
+	return items, nil
+}
+
 // MarkOmniFocusItemComplete marks a Item as complete. It only requires the
 // id field to be set.
 func MarkOmnifocusItemComplete(i Item) error {
@@ -61,6 +84,117 @@ func MarkOmnifocusItemComplete(i Item) error {
 	return nil
 }
 
+// MarkOmnifocusItemDropped marks a Item as dropped. It only requires the
+// id field to be set.
+func MarkOmnifocusItemDropped(i Item) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofmarktaskdropped.js")
+	args, _ := json.Marshal(i)
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// MarkOmnifocusItemIncomplete marks a completed or dropped Item as
+// incomplete. It only requires the id field to be set.
+func MarkOmnifocusItemIncomplete(i Item) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofmarktaskincomplete.js")
+	args, _ := json.Marshal(i)
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// DeleteOmnifocusItem deletes a Item. It only requires the id field to be set.
+func DeleteOmnifocusItem(i Item) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofdeletetask.js")
+	args, _ := json.Marshal(i)
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// AddTagToOmnifocusItem adds a tag to an existing Item, creating the tag if
+// it doesn't exist yet.
+func AddTagToOmnifocusItem(i Item, tag string) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofaddtagtotask.js")
+	args, _ := json.Marshal(struct {
+		ID  string `json:"id"`
+		Tag string `json:"tag"`
+	}{i.ID, tag})
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// RemoveTagFromOmnifocusItem removes a tag from an existing Item.
+func RemoveTagFromOmnifocusItem(i Item, tag string) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofremovetagfromtask.js")
+	args, _ := json.Marshal(struct {
+		ID  string `json:"id"`
+		Tag string `json:"tag"`
+	}{i.ID, tag})
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// UpdateOmnifocusItem changes the name, note, tags or due date of an existing
+// Item. Tags not in the update are removed from the Item.
+func UpdateOmnifocusItem(u ItemUpdate) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofupdatetask.js")
+	args, _ := json.Marshal(u)
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// RestoreOmnifocusItem restores the name, note, tags, due date, location and
+// status of an existing Item.
+func RestoreOmnifocusItem(s TaskState) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofrestoretask.js")
+	args, _ := json.Marshal(s)
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// MoveOmnifocusItem moves an existing Item to the end of the named project,
+// or to the inbox. It only requires the id field of the Item to be set.
+func MoveOmnifocusItem(i Item, projectName string, inbox bool) error {
+	jsCode, _ := jxa.ReadFile("jxa/ofmovetask.js")
+	args, _ := json.Marshal(struct {
+		ID          string `json:"id"`
+		ProjectName string `json:"projectName"`
+		Inbox       bool   `json:"inbox"`
+	}{i.ID, projectName, inbox})
+
+	_, err := executeScript(jsCode, args)
+	return err
+}
+
+// EnsureProjectExists creates a project, and the folders it is in, in
+// OmniFocus if it doesn't already exist. It returns whether the project was
+// created.
+func EnsureProjectExists(p NewProject) (bool, error) {
+	jsCode, _ := jxa.ReadFile("jxa/ofensureproject.js")
+	args, _ := json.Marshal(p)
+
+	out, err := executeScript(jsCode, args)
+	if err != nil {
+		return false, err
+	}
+
+	result := struct {
+		Created bool `json:"created"`
+	}{}
+	err = json.Unmarshal(out, &result)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Created, nil
+}
+
 // EnsureTagExists creates a tag in OmniFocus if it doesn't already exist.
 func EnsureTagExists(tag Tag) error {
 	jsCode, _ := jxa.ReadFile("jxa/ofensuretagexists.js")
@@ -121,7 +255,9 @@ func executeScript(jsCode []byte, args []byte) ([]byte, error) {
 	}()
 
 	out, err := cmd.Output()
-	if err != nil {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	} else if err != nil {
 		return nil, err
 	}
 
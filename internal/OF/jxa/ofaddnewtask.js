@@ -32,6 +32,8 @@ THIS SOFTWARE.
 /**
  * @typedef {Object} NewOmnifocusTask
  * @property {string} projectName
+ * @property {boolean} inbox add the task to the inbox instead of the project
+ * @property {string} parentId add the task under the open task with this ID instead
  * @property {string} name
  * @property {string[]} tags
  * @property {string} note
@@ -65,8 +67,25 @@ function addNewTask(
         ) : tags()[0]
     }
 
-    const project = ofDoc.flattenedProjects
-        .whose({ name: t.projectName })[0];
+    var parent = null
+    if (t.parentId) {
+        parent = ofDoc.flattenedTasks.whose({ id: t.parentId })[0]
+        if (!parent) {
+            throw new Error("Parent task `" + t.parentId + "` does not exist")
+        }
+        if (parent.completed()) {
+            throw new Error("Parent task `" + t.parentId + "` is completed")
+        }
+    }
+
+    var project = null
+    if (!t.inbox && !parent) {
+        const projects = ofDoc.flattenedProjects.whose({ name: t.projectName })
+        if (projects.length === 0) {
+            throw new Error("Project `" + t.projectName + "` does not exist")
+        }
+        project = projects[0]
+    }
 
     // Unmarshall dueDateMS into JS Date
     var dueDate = null
@@ -79,8 +98,13 @@ function addNewTask(
         "note": t.note,
         "dueDate": dueDate,
     })
-    // ofDoc.inboxTasks.push(task)
-    project.tasks.unshift(task)
+    if (parent) {
+        parent.tasks.push(task)
+    } else if (t.inbox) {
+        ofDoc.inboxTasks.push(task)
+    } else {
+        project.tasks.unshift(task)
+    }
     t.tags.forEach((t) => {
         ofApp.add(tagFoundOrCreated(t), {
             to: task.tags
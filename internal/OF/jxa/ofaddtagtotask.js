@@ -0,0 +1,50 @@
+// Add a tag to an existing task in OmniFocus, creating the tag if needed
+// Accepts a TaskTag as JSON in an OSA_ARGS env var
+// Call it:
+//   set -gx OSA_ARGS '{"id": "a2g4XFUiQKm", "tag": "orphaned"}'
+//   osascript -l JavaScript ofaddtagtotask.js | jq .
+
+/**
+ * @typedef {Object} TaskTag
+ * @property {string} id
+ * @property {string} tag
+ */
+
+function addTagToTask(
+    /** @type {TaskTag} */ t
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const tagFoundOrCreated = charTag => {
+        const
+            tags = ofDoc.flattenedTags.whose({
+                name: charTag
+            }),
+            oTag = ofApp.Tag({
+                name: charTag
+            });
+        return tags.length === 0 ? (
+            (
+                ofDoc.tags.push(oTag),
+                oTag
+            )
+        ) : tags()[0]
+    }
+
+    const task = ofDoc.flattenedTasks.whose({ id: t.id })[0]
+    if (task) {
+        ofApp.add(tagFoundOrCreated(t.tag), {
+            to: task.tags
+        })
+        return true
+    }
+    return false
+}
+
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = addTagToTask(args)
+JSON.stringify(out)
@@ -0,0 +1,86 @@
+// Ensure a project exists within OmniFocus, creating it and its folders if needed
+// Accepts a NewProject as JSON in an OSA_ARGS env var.
+// Call it:
+//   set -gx OSA_ARGS '{"name": "omnifocus-sync", "folders": ["Work", "Repos"], "type": "parallel", "reviewIntervalDays": 7, "tags": ["github"]}'
+//   osascript -l JavaScript ofensureproject.js | jq .
+// Returns JSON:
+// {
+//  "id": "k9TCngde98W",
+//  "created": true
+// }
+
+/**
+ * @typedef {Object} NewProject
+ * @property {string} name
+ * @property {string[]} folders the nested folders to create the project in, outermost first
+ * @property {string} type one of parallel, sequential or single-action
+ * @property {integer} reviewIntervalDays
+ * @property {string[]} tags
+ */
+
+function ensureProject(
+    /** @type {NewProject} */ p
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const existing = ofDoc.flattenedProjects.whose({ name: p.name })
+    if (existing.length > 0) {
+        return { "id": existing[0].id(), "created": false }
+    }
+
+    const tagFoundOrCreated = charTag => {
+        const
+            tags = ofDoc.flattenedTags.whose({
+                name: charTag
+            }),
+            oTag = ofApp.Tag({
+                name: charTag
+            });
+        return tags.length === 0 ? (
+            (
+                ofDoc.tags.push(oTag),
+                oTag
+            )
+        ) : tags()[0]
+    }
+
+    var container = ofDoc
+    const folders = p.folders || []
+    folders.forEach((name) => {
+        const found = container.folders.whose({ name: name })
+        if (found.length > 0) {
+            container = found[0]
+        } else {
+            const folder = ofApp.Folder({ "name": name })
+            container.folders.push(folder)
+            container = folder
+        }
+    })
+
+    const project = ofApp.Project({
+        "name": p.name,
+        "sequential": p.type === "sequential",
+        "singletonActionHolder": p.type === "single-action",
+    })
+    container.projects.push(project)
+
+    if (p.reviewIntervalDays) {
+        project.reviewInterval = { "unit": "day", "steps": p.reviewIntervalDays, "fixed": false }
+    }
+
+    const tags = p.tags || []
+    tags.forEach((t) => {
+        ofApp.add(tagFoundOrCreated(t), {
+            to: project.tags
+        })
+    })
+
+    return { "id": project.id(), "created": true }
+}
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = ensureProject(args)
+JSON.stringify(out)
@@ -1,36 +0,0 @@
-// Author: Mike Rhodes
-// Source: https://github.com/mikerhodes/github-to-omnifocus
-//
-/*
- * Copyright 2020 Mike Rhodes, https://dx13.co.uk/
-Permission to use, copy, modify, and/or distribute this software for any purpose
-with or without fee is hereby granted, provided that the above copyright notice
-and this permission notice appear in all copies.
-
-THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
-REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
-FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
-INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
-OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
-TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
-THIS SOFTWARE.
-*/
-
-//
-// A JS script to load up Omnifocus inbox tasks
-// Run it using:
-// 	osascript -l JavaScript ofinbox.js | jq .
-
-function inbox() {
-	var of = Application("OmniFocus")
-	of.includeStandardAdditions = true;
-	return of.defaultDocument
-		.inboxTasks()
-		.filter((task) => task.completed() === false)
-		.map((task) => {
-			return { "id": task.id(), "name": task.name() };
-		});
-}
-
-ObjC.import('stdlib')
-JSON.stringify(inbox())
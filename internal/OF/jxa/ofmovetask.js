@@ -0,0 +1,44 @@
+// Move an existing task in OmniFocus to the end of a project, or to the inbox
+// Accepts a TaskMove as JSON in an OSA_ARGS env var
+// Call it:
+//   set -gx OSA_ARGS '{"id": "a2g4XFUiQKm", "projectName": "OmniSync"}'
+//   osascript -l JavaScript ofmovetask.js | jq .
+
+/**
+ * @typedef {Object} TaskMove
+ * @property {string} id
+ * @property {string} projectName
+ * @property {boolean} inbox move the task to the inbox instead of a project
+ */
+
+function moveTask(
+    /** @type {TaskMove} */ m
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const task = ofDoc.flattenedTasks.whose({ id: m.id })[0]
+    if (!task) {
+        return false
+    }
+
+    if (m.inbox) {
+        ofApp.move(task, { to: ofDoc.inboxTasks.end })
+        return true
+    }
+
+    const projects = ofDoc.flattenedProjects.whose({ name: m.projectName })
+    if (projects.length === 0) {
+        throw new Error("Project `" + m.projectName + "` does not exist")
+    }
+
+    ofApp.move(task, { to: projects[0].tasks.end })
+    return true
+}
+
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = moveTask(args)
+JSON.stringify(out)
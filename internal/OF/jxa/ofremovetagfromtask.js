@@ -0,0 +1,38 @@
+// Remove a tag from an existing task in OmniFocus
+// Accepts a TaskTag as JSON in an OSA_ARGS env var
+// Call it:
+//   set -gx OSA_ARGS '{"id": "a2g4XFUiQKm", "tag": "orphaned"}'
+//   osascript -l JavaScript ofremovetagfromtask.js | jq .
+
+/**
+ * @typedef {Object} TaskTag
+ * @property {string} id
+ * @property {string} tag
+ */
+
+function removeTagFromTask(
+    /** @type {TaskTag} */ t
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const task = ofDoc.flattenedTasks.whose({ id: t.id })[0]
+    if (!task) {
+        return false
+    }
+
+    const tags = task.tags.whose({ name: t.tag })
+    if (tags.length > 0) {
+        ofApp.remove(tags[0], {
+            from: task.tags
+        })
+    }
+    return true
+}
+
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = removeTagFromTask(args)
+JSON.stringify(out)
@@ -0,0 +1,91 @@
+// Restore an existing task in OmniFocus to a previous state: its name, note,
+// tags, due date, location and whether it is completed or dropped
+// Accepts a TaskState as JSON in an OSA_ARGS env var
+// Call it:
+//   set -gx OSA_ARGS '{"id": "a2g4XFUiQKm", "name": "[257] Title", "note": "", "tags": ["github"], "dueDateMS": 0, "projectName": "OmniSync", "inbox": false, "completed": false, "dropped": false}'
+//   osascript -l JavaScript ofrestoretask.js | jq .
+
+/**
+ * @typedef {Object} TaskState
+ * @property {string} id
+ * @property {string} name
+ * @property {string} note
+ * @property {string[]} tags
+ * @property {integer} dueDateMS 0 for no due date
+ * @property {string} projectName
+ * @property {boolean} inbox
+ * @property {boolean} completed
+ * @property {boolean} dropped
+ */
+
+function restoreTask(
+    /** @type {TaskState} */ s
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const tagFoundOrCreated = charTag => {
+        const
+            tags = ofDoc.flattenedTags.whose({
+                name: charTag
+            }),
+            oTag = ofApp.Tag({
+                name: charTag
+            });
+        return tags.length === 0 ? (
+            (
+                ofDoc.tags.push(oTag),
+                oTag
+            )
+        ) : tags()[0]
+    }
+
+    const task = ofDoc.flattenedTasks.whose({ id: s.id })[0]
+    if (!task) {
+        return false
+    }
+
+    task.name = s.name
+    task.note = s.note
+    task.dueDate = s.dueDateMS ? new Date(s.dueDateMS) : null
+
+    const tags = s.tags || []
+    task.tags().forEach((tag) => {
+        if (!tags.includes(tag.name())) {
+            ofApp.remove(tag, { from: task.tags })
+        }
+    })
+    const names = task.tags().map((tag) => tag.name())
+    tags.forEach((name) => {
+        if (!names.includes(name)) {
+            ofApp.add(tagFoundOrCreated(name), { to: task.tags })
+        }
+    })
+
+    const project = task.containingProject()
+    if (s.inbox && !task.inInbox()) {
+        ofApp.move(task, { to: ofDoc.inboxTasks.end })
+    } else if (!s.inbox && s.projectName && (!project || project.name() !== s.projectName)) {
+        const projects = ofDoc.flattenedProjects.whose({ name: s.projectName })
+        if (projects.length > 0) {
+            ofApp.move(task, { to: projects[0].tasks.end })
+        }
+    }
+
+    if (s.completed && !task.completed()) {
+        ofApp.markComplete(task)
+    } else if (s.dropped && !task.dropped()) {
+        ofApp.markDropped(task)
+    } else if (!s.completed && !s.dropped && (task.completed() || task.dropped())) {
+        ofApp.markIncomplete(task)
+    }
+
+    return true
+}
+
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = restoreTask(args)
+JSON.stringify(out)
@@ -0,0 +1,88 @@
+//
+// Search every task in OmniFocus, whether it is in the inbox, a project or
+// nested under another task, for the tasks of a source. With tags, the tasks
+// having all of them are returned. Without tags, the tasks whose first line of
+// the note is one of the given notes are returned.
+// Accepts a TaskQuery as JSON in an OSA_ARGS env var, the projectName is ignored.
+// Call it:
+//   set -gx OSA_ARGS '{"tags": ["github"]}'
+//   osascript -l JavaScript ofsearchtasks.js | jq .
+//   set -gx OSA_ARGS '{"notes": ["https://github.com/trevorpiltch/omnifocus-sync/issues/257"]}'
+//   osascript -l JavaScript ofsearchtasks.js | jq .
+// Returns JSON array:
+// [
+//     {
+//       "id": "iAKv1Uo8XqW",
+//       "name": "[257] Document modernize search project progress",
+//       "tags": ["github"],
+//       "completed": false,
+//       "dropped": false,
+//       "projectName": "OmniSync",
+//       "inInbox": false
+//     }, ...
+// ]
+
+/**
+ * @typedef {Object} TaskQuery
+ * @property {string[]} tags
+ * @property {string[]} notes the URLs that identify the items of the source, only used without tags
+ * @property {boolean} includeClosed also return completed and dropped tasks
+ */
+
+function searchTasks(
+    /** @type {TaskQuery} */ query
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+    const tagNames = query.tags || []
+    const notes = new Set(query.notes || [])
+
+    let tasks = []
+    if (tagNames.length > 0) {
+        // Start from the tasks of the first tag rather than every task in the database
+        const first = ofDoc.flattenedTags.whose({ name: tagNames[0] })
+        if (first.length === 0) {
+            return []
+        }
+
+        tasks = first[0].tasks().filter((task) => {
+            // Task must have all tags
+            const names = task.tags().map((tag) => tag.name())
+            return tagNames.every((name) => names.includes(name))
+        })
+    } else if (notes.size > 0) {
+        // Read the IDs and notes of every task at once instead of asking each task for its note
+        const ids = ofDoc.flattenedTasks.id()
+        const taskNotes = ofDoc.flattenedTasks.note()
+        tasks = ids
+            .filter((id, i) => notes.has((taskNotes[i] || "").split("\n")[0].trim()))
+            .map((id) => ofDoc.flattenedTasks.byId(id))
+    } else {
+        // Without tags or notes every task in the database would match
+        return []
+    }
+
+    return tasks
+        .filter((task) => query.includeClosed || (task.completed() === false && task.dropped() === false))
+        .map((task) => {
+            const project = task.containingProject()
+            return {
+                "id": task.id(),
+                "name": task.name(),
+                "tags": task.tags().map((tag) => tag.name()),
+                "completed": task.completed(),
+                "dropped": task.dropped(),
+                "closedAt": task.completionDate() || task.droppedDate() || null,
+                "note": task.note(),
+                "dueDate": task.dueDate() || null,
+                "projectName": project ? project.name() : "",
+                "inInbox": task.inInbox(),
+            };
+        });
+}
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = searchTasks(args)
+JSON.stringify(out)
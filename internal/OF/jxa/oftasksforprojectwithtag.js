@@ -25,7 +25,10 @@ THIS SOFTWARE.
 // [
 //     {
 //       "id": "iAKv1Uo8XqW",
-//       "name": "cloudant/techspec-documents#257 Document modernize search project progress"
+//       "name": "cloudant/techspec-documents#257 Document modernize search project progress",
+//       "tags": ["github"],
+//       "completed": false,
+//       "dropped": false
 //     }, ...
 // ]
 
@@ -33,6 +36,7 @@ THIS SOFTWARE.
  * @typedef {Object} TaskQuery
  * @property {string} projectName
  * @property {string[]} tags
+ * @property {boolean} includeClosed also return completed and dropped tasks
  */
 
 function tasksForProjectWithTag(
@@ -41,8 +45,13 @@ function tasksForProjectWithTag(
     // @ts-ignore
     const ofApp = Application("OmniFocus")
     const ofDoc = ofApp.defaultDocument
-    const project = ofDoc.flattenedProjects
-        .whose({ name: query.projectName })[0];
+    const projects = ofDoc.flattenedProjects
+        .whose({ name: query.projectName });
+    if (projects.length === 0) {
+        // A project that doesn't exist yet can't have any tasks
+        return []
+    }
+    const project = projects[0];
 
     const tagFoundOrCreated = charTag => {
         const
@@ -68,7 +77,7 @@ function tasksForProjectWithTag(
     // const ofTypeTag = tagFoundOrCreated(typeTag)
 
     return project.tasks()
-        .filter((task) => task.completed() === false)
+        .filter((task) => query.includeClosed || (task.completed() === false && task.dropped() === false))
         .filter((task) => {
             // Task must have all tags
             const tags = task.tags()
@@ -80,7 +89,16 @@ function tasksForProjectWithTag(
             return true
         })
         .map((task) => {
-            return { "id": task.id(), "name": task.name() };
+            return {
+                "id": task.id(),
+                "name": task.name(),
+                "tags": task.tags().map((tag) => tag.name()),
+                "completed": task.completed(),
+                "dropped": task.dropped(),
+                "closedAt": task.completionDate() || task.droppedDate() || null,
+                "note": task.note(),
+                "dueDate": task.dueDate() || null,
+            };
         });
 }
 
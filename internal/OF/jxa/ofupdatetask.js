@@ -0,0 +1,75 @@
+// Change the fields of an existing task in OmniFocus
+// Accepts a TaskUpdate as JSON in an OSA_ARGS env var, fields that are left
+// out or null are unchanged
+// Call it:
+//   set -gx OSA_ARGS '{"id": "a2g4XFUiQKm", "name": "[257] New title", "tags": ["github"], "dueDateMS": 0}'
+//   osascript -l JavaScript ofupdatetask.js | jq .
+
+/**
+ * @typedef {Object} TaskUpdate
+ * @property {string} id
+ * @property {string} name
+ * @property {string} note
+ * @property {string[]} tags the complete set of tags of the task
+ * @property {integer} dueDateMS 0 clears the due date
+ */
+
+function updateTask(
+    /** @type {TaskUpdate} */ u
+) {
+    // @ts-ignore
+    const ofApp = Application("OmniFocus")
+    const ofDoc = ofApp.defaultDocument
+
+    const tagFoundOrCreated = charTag => {
+        const
+            tags = ofDoc.flattenedTags.whose({
+                name: charTag
+            }),
+            oTag = ofApp.Tag({
+                name: charTag
+            });
+        return tags.length === 0 ? (
+            (
+                ofDoc.tags.push(oTag),
+                oTag
+            )
+        ) : tags()[0]
+    }
+
+    const task = ofDoc.flattenedTasks.whose({ id: u.id })[0]
+    if (!task) {
+        return false
+    }
+
+    if (u.name !== undefined) {
+        task.name = u.name
+    }
+    if (u.note !== undefined) {
+        task.note = u.note
+    }
+    if (u.dueDateMS !== undefined) {
+        task.dueDate = u.dueDateMS ? new Date(u.dueDateMS) : null
+    }
+    if (u.tags !== undefined && u.tags !== null) {
+        task.tags().forEach((tag) => {
+            if (!u.tags.includes(tag.name())) {
+                ofApp.remove(tag, { from: task.tags })
+            }
+        })
+        const names = task.tags().map((tag) => tag.name())
+        u.tags.forEach((name) => {
+            if (!names.includes(name)) {
+                ofApp.add(tagFoundOrCreated(name), { to: task.tags })
+            }
+        })
+    }
+
+    return true
+}
+
+
+ObjC.import('stdlib')
+var args = JSON.parse($.getenv('OSA_ARGS'))
+var out = updateTask(args)
+JSON.stringify(out)
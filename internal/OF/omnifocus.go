@@ -4,6 +4,7 @@ import (
 	"embed"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/trevorpiltch/omnifocus-sync/internal/project"
 )
@@ -15,8 +16,20 @@ var (
 
 // Item is an existing item in OmniFocus
 type Item struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Tags      []string  `json:"tags"`
+	Completed bool      `json:"completed"`
+	Dropped   bool      `json:"dropped"`
+	Note      string    `json:"note"`
+	DueDate   time.Time `json:"dueDate"`
+	// ClosedAt is when the item was completed or dropped
+	ClosedAt time.Time `json:"closedAt"`
+	// SyncKey is the key of the source item the task was last synced with, when the task was renamed since
+	SyncKey string `json:"-"`
+	// ProjectName and InInbox say where the item is. They are only set by database wide queries
+	ProjectName string `json:"projectName,omitempty"`
+	InInbox     bool   `json:"inInbox,omitempty"`
 }
 
 func (i Item) String() string {
@@ -25,13 +38,37 @@ func (i Item) String() string {
 
 // Key returns the string representation of the item for conformance to Delta's key interface
 func (i Item) Key() string {
+	if i.SyncKey != "" {
+		return i.SyncKey
+	}
+
 	return i.Name
 }
 
+// IsClosed returns whether the item is completed or dropped for conformance to Delta's closable interface
+func (i Item) IsClosed() bool {
+	return i.Completed || i.Dropped
+}
+
+// HasTag returns whether the item carries the given tag
+func (i Item) HasTag(tag string) bool {
+	for _, t := range i.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ItemQuery defines a query to find OmniFocus items with the given criteria
 type ItemQuery struct {
 	ProjectName string   `json:"projectName"`
 	Tags        []string `json:"tags"`
+	// Notes identify items by the first line of their note, for searches across the whole database without tags
+	Notes []string `json:"notes,omitempty"`
+	// IncludeClosed also returns completed and dropped items
+	IncludeClosed bool `json:"includeClosed"`
 }
 
 // Tag represents a tag in OmniFocus
@@ -39,32 +76,115 @@ type Tag struct {
 	Name string
 }
 
-// NewOmniFocusItem defines a request to create a new Item in OmniFocus
-type NewOmniFocusItem struct {
-	ProjectName string   `json:"projectName"`
+// ItemUpdate defines changes to the fields of an existing Item. Fields left nil are unchanged
+type ItemUpdate struct {
+	ID   string   `json:"id"`
+	Name *string  `json:"name,omitempty"`
+	Note *string  `json:"note,omitempty"`
+	// Tags is the complete set of tags of the item. An empty slice removes every tag
+	Tags []string `json:"tags"`
+	// DueDateMS is the new due date in milliseconds since the epoch, 0 clears it
+	DueDateMS *int64 `json:"dueDateMS,omitempty"`
+}
+
+// TaskState is everything about an Item that can be restored
+type TaskState struct {
+	ID          string   `json:"id"`
 	Name        string   `json:"name"`
-	Tags        []string `json:"tags"`
 	Note        string   `json:"note"`
+	Tags        []string `json:"tags"`
 	DueDateMS   int64    `json:"dueDateMS"`
+	ProjectName string   `json:"projectName"`
+	Inbox       bool     `json:"inbox"`
+	Completed   bool     `json:"completed"`
+	Dropped     bool     `json:"dropped"`
+}
+
+// State returns the current state of the item, so it can be restored later
+func (i Item) State() TaskState {
+	var due int64
+	if !i.DueDate.IsZero() {
+		due = i.DueDate.UnixMilli()
+	}
+
+	return TaskState{
+		ID:          i.ID,
+		Name:        i.Name,
+		Note:        i.Note,
+		Tags:        i.Tags,
+		DueDateMS:   due,
+		ProjectName: i.ProjectName,
+		Inbox:       i.InInbox,
+		Completed:   i.Completed,
+		Dropped:     i.Dropped,
+	}
+}
+
+// NewProject defines a request to create a new project in OmniFocus
+type NewProject struct {
+	Name               string   `json:"name"`
+	Folders            []string `json:"folders"`
+	Type               string   `json:"type"`
+	ReviewIntervalDays int      `json:"reviewIntervalDays"`
+	Tags               []string `json:"tags"`
+}
+
+// NewOmniFocusItem defines a request to create a new Item in OmniFocus
+type NewOmniFocusItem struct {
+	ProjectName string `json:"projectName"`
+	// Inbox adds the item to the OmniFocus Inbox instead of a project
+	Inbox     bool     `json:"inbox"`
+	Name      string   `json:"name"`
+	Tags      []string `json:"tags"`
+	Note      string   `json:"note"`
+	DueDateMS int64    `json:"dueDateMS"`
+	// ParentNote is the note of the item this item is a subtask of. Subtasks are added under the parent's task
+	ParentNote string `json:"-"`
+	// ParentID is the ID of the parent's task the subtask is added under
+	ParentID string `json:"parentId,omitempty"`
+	// Closed is set when the source reports the item as closed, so its task should be completed
+	Closed bool `json:"-"`
+	// CloseReason is why the source closed the item, if it says
+	CloseReason string `json:"-"`
+	// Fields are the raw fields of the item as returned by its source
+	Fields map[string]interface{} `json:"-"`
 }
 
 func (i NewOmniFocusItem) Key() string {
 	return i.Name
 }
 
+// IsClosed returns whether the source reported the item as closed for conformance to Delta's closable interface
+func (i NewOmniFocusItem) IsClosed() bool {
+	return i.Closed
+}
+
 func (i NewOmniFocusItem) String() string {
+	if i.Inbox {
+		return fmt.Sprintf("[Inbox] %s", i.Name)
+	}
+
 	return fmt.Sprintf("[%s] %s", i.ProjectName, i.Name)
 }
 
-// GetAllItems returns an array containing all of the items with the given tags for the given list of projects from OmniFocus
-func GetAllItems(projects []project.Project, tags []string) ([]Item, error) {
+// GetAllItems returns an array containing all of the items with the given tags for the given list of projects from OmniFocus.
+// Completed and dropped items are only included when includeClosed is set.
+func GetAllItems(projects []project.Project, tags []string, includeClosed bool) ([]Item, error) {
 	log.Print("[OF] Getting all items")
 	items := []Item{}
 
+	// Several projects can route to the same OmniFocus project, which only needs to be queried once
+	queried := map[string]bool{}
 	for _, project := range projects {
+		if queried[project.OFName] {
+			continue
+		}
+		queried[project.OFName] = true
+
 		query := ItemQuery{
-			ProjectName: project.OFName,
-			Tags:        tags,
+			ProjectName:   project.OFName,
+			Tags:          tags,
+			IncludeClosed: includeClosed,
 		}
 		projectItems, err := ItemsForQuery(query)
 
@@ -72,12 +192,31 @@ func GetAllItems(projects []project.Project, tags []string) ([]Item, error) {
 			return nil, err
 		}
 
+		for i := range projectItems {
+			projectItems[i].ProjectName = project.OFName
+		}
+
 		items = append(items, projectItems[:]...)
 	}
 
 	return items, nil
 }
 
+// FindItems returns an array containing all of the items anywhere in OmniFocus that have all the given
+// tags, or without tags whose note starts with one of the given notes, so tasks are found even after they
+// were moved out of the Inbox, into another project or under another task. Completed and dropped items are
+// only included when includeClosed is set.
+func FindItems(tags []string, notes []string, includeClosed bool) ([]Item, error) {
+	log.Print("[OF] Searching all items")
+	query := ItemQuery{
+		Tags:          tags,
+		Notes:         notes,
+		IncludeClosed: includeClosed,
+	}
+
+	return SearchItemsForQuery(query)
+}
+
 // GetItems returns n array containing all of the items with the tags from the given project in OmniFocus
 func GetItems(project project.Project, tags []string) ([]Item, error) {
 	log.Printf("[omnifocus] Getting items from %s", project)
@@ -94,17 +233,17 @@ func GetItems(project project.Project, tags []string) ([]Item, error) {
 	return items, nil
 }
 
-// AddItems adds the item to the OmniFocus application
-func AddItem(i NewOmniFocusItem) error {
+// AddItems adds the item to the OmniFocus application and returns the added item
+func AddItem(i NewOmniFocusItem) (Item, error) {
 	log.Printf("[OF] Adding item %s", i)
 	log.Printf("AddItem: %s", i)
 
-	_, err := AddNewOmnifocusItem(i)
+	item, err := AddNewOmnifocusItem(i)
 	if err != nil {
-		return fmt.Errorf("failed to add item: %v", err)
+		return Item{}, fmt.Errorf("failed to add item: %v", err)
 	}
 
-	return nil
+	return item, nil
 }
 
 // CompleteItem completes the item in the OmniFocus application
@@ -117,3 +256,122 @@ func CompleteItem(i Item) error {
 
 	return nil
 }
+
+// DropItem drops the item in the OmniFocus application
+func DropItem(i Item) error {
+	log.Printf("[OF] Drop item %s", i)
+	err := MarkOmnifocusItemDropped(i)
+	if err != nil {
+		return fmt.Errorf("failed to drop item: %v", err)
+	}
+
+	return nil
+}
+
+// TagItem adds the tag to the item in the OmniFocus application
+func TagItem(i Item, tag string) error {
+	log.Printf("[OF] Tag item %s with %s", i, tag)
+	err := AddTagToOmnifocusItem(i, tag)
+	if err != nil {
+		return fmt.Errorf("failed to tag item: %v", err)
+	}
+
+	return nil
+}
+
+// UntagItem removes the tag from the item in the OmniFocus application
+func UntagItem(i Item, tag string) error {
+	log.Printf("[OF] Remove tag %s from item %s", tag, i)
+	err := RemoveTagFromOmnifocusItem(i, tag)
+	if err != nil {
+		return fmt.Errorf("failed to untag item: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteItem deletes the item from the OmniFocus application
+func DeleteItem(i Item) error {
+	log.Printf("[OF] Delete item %s", i)
+	err := DeleteOmnifocusItem(i)
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %v", err)
+	}
+
+	return nil
+}
+
+// ReopenItem marks a completed or dropped item as incomplete in the OmniFocus application
+func ReopenItem(i Item) error {
+	log.Printf("[OF] Reopen item %s", i)
+	err := MarkOmnifocusItemIncomplete(i)
+	if err != nil {
+		return fmt.Errorf("failed to reopen item: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateItem changes the fields of the item in the OmniFocus application
+func UpdateItem(i Item, u ItemUpdate) error {
+	log.Printf("[OF] Update item %s", i)
+	u.ID = i.ID
+	err := UpdateOmnifocusItem(u)
+	if err != nil {
+		return fmt.Errorf("failed to update item: %v", err)
+	}
+
+	return nil
+}
+
+// RestoreItem restores the item to the given state in the OmniFocus application
+func RestoreItem(s TaskState) error {
+	log.Printf("[OF] Restore item [%s] %s", s.ID, s.Name)
+	err := RestoreOmnifocusItem(s)
+	if err != nil {
+		return fmt.Errorf("failed to restore item: %v", err)
+	}
+
+	return nil
+}
+
+// MoveItem moves the item to the end of the named project, or to the Inbox when inbox is set
+func MoveItem(i Item, projectName string, inbox bool) error {
+	if inbox {
+		log.Printf("[OF] Move item %s to the Inbox", i)
+	} else {
+		log.Printf("[OF] Move item %s to %s", i, projectName)
+	}
+
+	err := MoveOmnifocusItem(i, projectName, inbox)
+	if err != nil {
+		return fmt.Errorf("failed to move item: %v", err)
+	}
+
+	return nil
+}
+
+// EnsureProject creates the project in the OmniFocus application if it doesn't exist and the project
+// is configured to be created
+func EnsureProject(p project.Project) error {
+	if p.Create == nil {
+		return nil
+	}
+
+	created, err := EnsureProjectExists(NewProject{
+		Name:               p.OFName,
+		Folders:            p.Create.FolderPath(),
+		Type:               string(p.Create.GetType()),
+		ReviewIntervalDays: p.Create.ReviewIntervalDays,
+		Tags:               p.Create.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create project %s: %v", p.OFName, err)
+	}
+
+	if created {
+		log.Printf("[OF] Created project %s", p.OFName)
+	}
+
+	return nil
+}
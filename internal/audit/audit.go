@@ -0,0 +1,256 @@
+// Package audit keeps an append-only log of every run in JSON Lines: when it started and ended, which
+// configuration it used, what each source returned and every change it made to OmniFocus.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// EventType says what an event records
+type EventType string
+
+const (
+	// EventStart is the start of a run
+	EventStart EventType = "start"
+	// EventFetch is the result of fetching the items of a source
+	EventFetch EventType = "fetch"
+	// EventOperation is a change to a task
+	EventOperation EventType = "operation"
+	// EventEnd is the end of a run
+	EventEnd EventType = "end"
+)
+
+// Outcome says how an operation went
+type Outcome string
+
+const (
+	// OutcomeApplied means the change was made
+	OutcomeApplied Outcome = "applied"
+	// OutcomeFailed means the change failed
+	OutcomeFailed Outcome = "failed"
+	// OutcomeSkipped means the change was held back, e.g. by a safety limit
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// fileName is the name of the audit log in the state directory
+const fileName = "audit.jsonl"
+
+// Event is one line of the audit log
+type Event struct {
+	Time time.Time `json:"time"`
+	Run  string    `json:"run"`
+	Type EventType `json:"type"`
+	// Version and ConfigHash are set on start events
+	Version    string `json:"version,omitempty"`
+	ConfigHash string `json:"configHash,omitempty"`
+	// Source is the source a fetch or operation belongs to
+	Source string `json:"source,omitempty"`
+	// Items is the number of items a source returned
+	Items int `json:"items,omitempty"`
+	// Action, ExternalID, TaskID, Name and Outcome describe an operation
+	Action     string  `json:"action,omitempty"`
+	ExternalID string  `json:"externalId,omitempty"`
+	TaskID     string  `json:"taskId,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Outcome    Outcome `json:"outcome,omitempty"`
+	// Error is why a fetch, operation or run failed
+	Error string `json:"error,omitempty"`
+}
+
+// Run summarizes the events of one run
+type Run struct {
+	ID         string    `json:"id"`
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	Version    string    `json:"version,omitempty"`
+	ConfigHash string    `json:"configHash,omitempty"`
+	Sources    int       `json:"sources"`
+	Applied    int       `json:"applied"`
+	Failed     int       `json:"failed"`
+	Skipped    int       `json:"skipped"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Log appends the events of a run to the audit log
+type Log struct {
+	path string
+	run  string
+	now  func() time.Time
+}
+
+// MARK: Private helper methods
+// errorString returns the message of the error, or an empty string without one
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// write appends the event to the log
+func (l *Log) write(e Event) error {
+	e.Time = l.now()
+	e.Run = l.run
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	err = os.MkdirAll(path.Dir(l.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(bytes, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// MARK: Public methods
+// Open returns the audit log of the run with the given ID, kept in the given directory
+func Open(dir, run string) *Log {
+	return &Log{
+		path: path.Join(dir, fileName),
+		run:  run,
+		now:  time.Now,
+	}
+}
+
+// ConfigHash returns a hash of the contents of the given files, so runs with different configurations
+// can be told apart. Missing files are skipped.
+func ConfigHash(files ...string) string {
+	h := sha256.New()
+	for _, f := range files {
+		bytes, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(h, "%s\n%d\n", path.Base(f), len(bytes))
+		h.Write(bytes)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// Start records the start of the run
+func (l *Log) Start(version, configHash string) error {
+	return l.write(Event{Type: EventStart, Version: version, ConfigHash: configHash})
+}
+
+// Fetch records the result of fetching the items of a source
+func (l *Log) Fetch(source string, items int, err error) error {
+	return l.write(Event{Type: EventFetch, Source: source, Items: items, Error: errorString(err)})
+}
+
+// Operation records a change to a task and how it went
+func (l *Log) Operation(source, action, externalID, taskID, name string, outcome Outcome, err error) error {
+	return l.write(Event{
+		Type:       EventOperation,
+		Source:     source,
+		Action:     action,
+		ExternalID: externalID,
+		TaskID:     taskID,
+		Name:       name,
+		Outcome:    outcome,
+		Error:      errorString(err),
+	})
+}
+
+// End records the end of the run
+func (l *Log) End(err error) error {
+	return l.write(Event{Type: EventEnd, Error: errorString(err)})
+}
+
+// Events returns the events of the audit log in the given directory, only those of the given run unless
+// it is empty
+func Events(dir, run string) ([]Event, error) {
+	f, err := os.Open(path.Join(dir, fileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to decode audit log line %d: %w", line, err)
+		}
+
+		if run == "" || e.Run == run {
+			events = append(events, e)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return events, nil
+}
+
+// Runs returns a summary of every run in the audit log in the given directory, oldest first
+func Runs(dir string) ([]Run, error) {
+	events, err := Events(dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	index := map[string]int{}
+	for _, e := range events {
+		i, ok := index[e.Run]
+		if !ok {
+			i = len(runs)
+			index[e.Run] = i
+			runs = append(runs, Run{ID: e.Run, StartedAt: e.Time})
+		}
+
+		r := &runs[i]
+		switch e.Type {
+		case EventStart:
+			r.StartedAt = e.Time
+			r.Version = e.Version
+			r.ConfigHash = e.ConfigHash
+		case EventFetch:
+			r.Sources++
+		case EventOperation:
+			switch e.Outcome {
+			case OutcomeApplied:
+				r.Applied++
+			case OutcomeFailed:
+				r.Failed++
+			case OutcomeSkipped:
+				r.Skipped++
+			}
+		case EventEnd:
+			r.EndedAt = e.Time
+			r.Error = e.Error
+		}
+	}
+
+	return runs, nil
+}
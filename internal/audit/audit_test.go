@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+var started = time.Date(2023, 11, 14, 10, 0, 0, 0, time.UTC)
+
+// clock returns a log whose events are a second apart, starting at `started`
+func clock(dir, run string) *Log {
+	l := Open(dir, run)
+	at := started
+	l.now = func() time.Time {
+		at = at.Add(time.Second)
+		return at
+	}
+	return l
+}
+
+// MARK: Audit tests
+// Tests that the events of every run are appended to the log and summarized per run
+func TestRuns(t *testing.T) {
+	dir := t.TempDir()
+
+	first := clock(dir, "first")
+	first.Start("1.0.0", "abc")
+	first.Fetch("GitHub", 2, nil)
+	first.Fetch("Jira", 0, errors.New("unexpected status 500"))
+	first.Operation("GitHub", "add", "https://github.com/1", "a1", "[1] One", OutcomeApplied, nil)
+	first.Operation("GitHub", "complete", "", "a2", "[2] Two", OutcomeSkipped, errors.New("too many"))
+	first.End(errors.New("1 of 2 sources failed"))
+
+	second := clock(dir, "second")
+	second.Start("1.0.0", "abc")
+	second.Operation("GitHub", "update", "https://github.com/1", "a1", "[1] One", OutcomeFailed, errors.New("boom"))
+
+	runs, err := Runs(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs, was: %+v", runs)
+	}
+
+	r := runs[0]
+	if r.ID != "first" || r.Sources != 2 || r.Applied != 1 || r.Skipped != 1 || r.Failed != 0 || r.ConfigHash != "abc" {
+		t.Errorf("Unexpected run: %+v", r)
+	}
+	if r.EndedAt.Sub(r.StartedAt) != 5*time.Second || r.Error != "1 of 2 sources failed" {
+		t.Errorf("Unexpected end of run: %+v", r)
+	}
+
+	// A run that never ended has no end time
+	r = runs[1]
+	if r.ID != "second" || r.Failed != 1 || !r.EndedAt.IsZero() {
+		t.Errorf("Unexpected run: %+v", r)
+	}
+
+	events, err := Events(dir, "first")
+	if err != nil || len(events) != 6 {
+		t.Fatalf("Unexpected events: %+v (%v)", events, err)
+	}
+
+	if e := events[3]; e.Type != EventOperation || e.ExternalID != "https://github.com/1" || e.TaskID != "a1" || e.Outcome != OutcomeApplied {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	if e := events[2]; e.Type != EventFetch || e.Error != "unexpected status 500" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+}
+
+// Tests that a missing log has no runs and a corrupt one reports its line
+func TestEvents(t *testing.T) {
+	dir := t.TempDir()
+
+	runs, err := Runs(dir)
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("Expected no runs, was: %+v (%v)", runs, err)
+	}
+
+	clock(dir, "first").Start("1.0.0", "abc")
+
+	f, _ := os.OpenFile(path.Join(dir, fileName), os.O_APPEND|os.O_WRONLY, 0o600)
+	f.WriteString("{not json\n")
+	f.Close()
+
+	_, err = Events(dir, "")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, was: %v", err)
+	}
+}
+
+// Tests that the config hash changes with the contents of the config and ignores missing files
+func TestConfigHash(t *testing.T) {
+	dir := t.TempDir()
+	p := path.Join(dir, "sources.json")
+
+	os.WriteFile(p, []byte(`[]`), 0o600)
+	before := ConfigHash(p, path.Join(dir, "missing.json"))
+
+	if before != ConfigHash(p) {
+		t.Errorf("Expected missing files to be ignored")
+	}
+
+	os.WriteFile(p, []byte(`[{}]`), 0o600)
+	if after := ConfigHash(p); after == before {
+		t.Errorf("Expected the hash to change, was: %s", after)
+	}
+}
@@ -0,0 +1,241 @@
+// Package config loads the configuration of OmniSync, either from a single `omnisync.yaml`, `.toml` or
+// `.json` file or from the separate JSON files of the projects, sources and global settings, which
+// apply to every source unless the source overrides them.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/schedule"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+// FileName is the name of the single configuration file, without its extension
+const FileName = "omnisync"
+
+// Config is the whole configuration of OmniSync
+type Config struct {
+	// The global settings
+	Settings Settings `json:"Settings"`
+	// The sources items are fetched from, each with the projects that only route its own items
+	Sources []source.Source `json:"Sources"`
+	// The projects that route the items of every source
+	Projects []project.Project `json:"Projects"`
+	// Named variations of the configuration, of which one can be selected
+	Profiles []Profile `json:"Profiles"`
+}
+
+// Settings are the global settings of OmniSync
+type Settings struct {
+	// Where items that don't match any project go, unless their source says otherwise. Unmatched items
+	// are skipped when neither sets a fallback
+	Unmatched project.Fallback `json:"Unmatched"`
+	// When sources are synced by `omnisync daemon`, unless they set their own schedule. Defaults to every 15 minutes
+	Schedule schedule.Schedule `json:"Schedule"`
+	// The address `omnisync daemon` accepts the webhooks of the sources on, e.g. `127.0.0.1:8787`. Leave empty to
+	// only poll the sources
+	Listen string `json:"Listen"`
+}
+
+// LoadSettings parses the optional `settings.json` file at the given path. The default settings are
+// returned when the file doesn't exist.
+func LoadSettings(Path string) (Settings, error) {
+	settingsPath := path.Join(Path, "settings.json")
+
+	var settings Settings
+
+	bytes, err := os.ReadFile(settingsPath)
+	if os.IsNotExist(err) {
+		return settings, nil
+	} else if err != nil {
+		return settings, fmt.Errorf("failed to load settings from %s", Path)
+	}
+
+	log.Printf("[config] Getting settings from: %s\n", Path)
+
+	err = diag.Decode(settingsPath, bytes, &settings)
+	if err != nil {
+		return settings, fmt.Errorf("failed to decode settings: %w", err)
+	}
+
+	err = settings.Validate()
+	if err != nil {
+		return settings, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// Validate returns an error if the settings can't be used
+func (settings Settings) Validate() error {
+	if err := settings.Unmatched.Validate(); err != nil {
+		return err
+	}
+
+	if settings.Listen != "" {
+		if _, _, err := net.SplitHostPort(settings.Listen); err != nil {
+			return fmt.Errorf("invalid `Listen` address `%s`, expected host:port", settings.Listen)
+		}
+	}
+
+	return settings.Schedule.Validate()
+}
+
+// Check returns the problems of the settings, which are all about the whole file
+func (settings Settings) Check() []diag.Problem {
+	if err := settings.Validate(); err != nil {
+		return []diag.Problem{{Index: -1, Severity: diag.SeverityError, Message: fmt.Sprintf("invalid settings: %s", err)}}
+	}
+
+	return nil
+}
+
+// Find returns the single configuration file in the directory, or an empty string when the configuration is
+// in separate JSON files
+func Find(dir string) (string, error) {
+	var found []string
+	for _, e := range extensions {
+		file := path.Join(dir, FileName+e.ext)
+		if _, err := os.Stat(file); err == nil {
+			found = append(found, file)
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to find the configuration in %s: %w", dir, err)
+		}
+	}
+
+	if len(found) > 1 {
+		return "", fmt.Errorf("both %s and %s exist, remove one of them", found[0], found[1])
+	}
+
+	if len(found) == 0 {
+		return "", nil
+	}
+
+	return found[0], nil
+}
+
+// Files returns the files the configuration in the directory is read from
+func Files(dir string) []string {
+	if file, err := Find(dir); err == nil && file != "" {
+		return []string{file}
+	}
+
+	return []string{
+		path.Join(dir, "projects.json"),
+		path.Join(dir, "sources.json"),
+		path.Join(dir, "settings.json"),
+	}
+}
+
+// Load returns the configuration in the directory, from its single configuration file when it has one and
+// from `projects.json`, `sources.json` and `settings.json` otherwise
+func Load(dir string) (Config, error) {
+	file, err := Find(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if file != "" {
+		return LoadFile(file)
+	}
+
+	projects, err := project.LoadProjects(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	sources, err := source.LoadSources(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	settings, err := LoadSettings(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{Settings: settings, Sources: sources, Projects: projects}, nil
+}
+
+// LoadFile returns the configuration in the file, in the format of its extension
+func LoadFile(file string) (Config, error) {
+	log.Printf("[config] Getting configuration from: %s\n", file)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to load configuration from %s", file)
+	}
+
+	return decode(file, data)
+}
+
+// decode returns the configuration in the data of the file
+func decode(file string, data []byte) (Config, error) {
+	var cfg Config
+
+	doc, err := parse(file, data)
+	if err == nil {
+		err = doc.decode(&cfg)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+
+	err = project.Validate(cfg.Projects)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = source.ValidateProjects(cfg.Sources)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = cfg.Settings.Validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	err = cfg.validateProfiles()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Check returns every problem of the configuration file at its position in the file
+func Check(file string) []diag.Diagnostic {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return []diag.Diagnostic{{File: file, Severity: diag.SeverityError, Message: fmt.Sprintf("failed to read: %s", err)}}
+	}
+
+	var cfg Config
+
+	doc, err := parse(file, data)
+	if err == nil {
+		err = doc.decode(&cfg)
+	}
+	if err != nil {
+		var d diag.Diagnostic
+		if !errors.As(err, &d) {
+			d = diag.Diagnostic{File: file, Severity: diag.SeverityError, Message: err.Error()}
+		}
+
+		return []diag.Diagnostic{d}
+	}
+
+	diags := doc.unknown(&cfg)
+	diags = append(diags, doc.locate("Sources", source.Check(cfg.Sources))...)
+	diags = append(diags, doc.locate("Projects", project.Check(cfg.Projects))...)
+	diags = append(diags, doc.locate("Profiles", cfg.checkProfiles())...)
+	return append(diags, doc.locate("", cfg.Settings.Check())...)
+}
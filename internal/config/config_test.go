@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+)
+
+// MARK: SETUP
+// testDir is the source for where tests look the config file
+const testDir = "../../testData/"
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// MARK: LoadSettings tests
+// Tests the `LoadSettings` function with nominal data
+func TestLoadSettingsSuccess(t *testing.T) {
+	settings, err := LoadSettings(testDir)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if settings.Unmatched != (project.Fallback{Project: "Triage"}) {
+		t.Fatalf("Unexpected fallback: %v", settings.Unmatched)
+	}
+}
+
+// Tests that the default settings are used when there is no settings.json file
+func TestLoadSettingsNoFile(t *testing.T) {
+	settings, err := LoadSettings("notadirectory")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if settings.Unmatched.IsSet() {
+		t.Fatalf("Unexpected fallback: %v", settings.Unmatched)
+	}
+}
+
+// Tests that a fallback with two destinations is rejected
+func TestLoadSettingsInvalidFallback(t *testing.T) {
+	_, err := LoadSettings(testDir + "invalid")
+	if err == nil || err.Error() != "invalid settings: a fallback can only set one of `Project`, `Inbox` or `Skip`" {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+}
+
+// Tests that the listen address of the daemon needs a port
+func TestSettingsValidateListen(t *testing.T) {
+	if err := (Settings{Listen: "127.0.0.1:8787"}).Validate(); err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	err := Settings{Listen: "localhost"}.Validate()
+	if err == nil || err.Error() != "invalid `Listen` address `localhost`, expected host:port" {
+		t.Errorf("Unexpected err: %v", err)
+	}
+}
+
+// MARK: LoadFile tests
+// Tests that a YAML file holds the settings, sources with their own projects and projects, with anchors merged
+func TestLoadFileYAML(t *testing.T) {
+	file := path.Join(t.TempDir(), "omnisync.yaml")
+	data := `Settings:
+  Unmatched:
+    Project: Triage
+Sources:
+  - &github
+    Name: GitHub
+    URL: https://api.github.com/issues
+    Response: {Title: title, URL: html_url, Number: number}
+    Projects:
+      - URL: https://github.com/org/app
+        OFName: App
+  - <<: *github
+    Name: GitHub Notifications
+    Safety:
+      MaxCompletePercent: 25
+Projects:
+  - URL: https://github.com
+    OFName: GitHub
+`
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFile(file)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if cfg.Settings.Unmatched.Project != "Triage" || len(cfg.Sources) != 2 || len(cfg.Projects) != 1 {
+		t.Fatalf("Unexpected configuration: %+v", cfg)
+	}
+
+	second := cfg.Sources[1]
+	if second.Name != "GitHub Notifications" || second.URL != "https://api.github.com/issues" || second.Safety.MaxCompletePercent == nil || *second.Safety.MaxCompletePercent != 25 {
+		t.Errorf("Unexpected merged source: %+v", second)
+	}
+
+	if len(second.Projects) != 1 || second.Projects[0].OFName != "App" {
+		t.Errorf("Unexpected projects of the source: %+v", second.Projects)
+	}
+}
+
+// Tests that a TOML file is read, and that errors are reported at their line in YAML and TOML
+func TestLoadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data, expected string
+	}{
+		{"omnisync.toml", "[[Sources]]\nName = \"GitHub\"\nURL = \"https://api.github.com\"\n", ""},
+		{"omnisync.yaml", "Sources:\n  - Name: GitHub\n    Tags: tag\n", "failed to decode configuration: " + path.Join(dir, "omnisync.yaml") + ":3:5: `Sources.Tags` can't be a JSON string, expected a []string"},
+		{"omnisync.yml", "Sources:\n  - Name: [\n", "failed to decode configuration: " + path.Join(dir, "omnisync.yml") + ":2: did not find expected node content"},
+		{"omnisync.toml", "[Sources\n", "failed to decode configuration: " + path.Join(dir, "omnisync.toml") + ":1:9: expected '.' or ']' to end table name, but got '\\n' instead"},
+		{"omnisync.ini", "", "failed to decode configuration: unknown format of " + path.Join(dir, "omnisync.ini") + ", expected a .yaml, .yml, .toml or .json file"},
+		{"omnisync.json", `{"Projects": [{"URL": "github.com"}]}`, "invalid project github.com: either `OFName` or `NameTemplate` is required"},
+	}
+
+	for _, test := range tests {
+		file := path.Join(dir, test.name)
+		if err := os.WriteFile(file, []byte(test.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadFile(file)
+		if (test.expected == "" && err != nil) || (test.expected != "" && (err == nil || err.Error() != test.expected)) {
+			t.Errorf("Unexpected err for %q: %v", test.data, err)
+		}
+	}
+}
+
+// MARK: Load tests
+// Tests that the single configuration file is used over the JSON files, and that only one may exist
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"projects.json", "sources.json", "settings.json"} {
+		data, err := os.ReadFile(path.Join(testDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(dir)
+	if err != nil || len(cfg.Sources) != 2 || len(cfg.Projects) != 2 || cfg.Settings.Unmatched.Project != "Triage" {
+		t.Fatalf("Unexpected configuration: %+v, err: %v", cfg, err)
+	}
+
+	if files := Files(dir); len(files) != 3 {
+		t.Errorf("Unexpected files: %v", files)
+	}
+
+	file := path.Join(dir, "omnisync.toml")
+	if err := os.WriteFile(file, []byte("[[Sources]]\nName = \"GitHub\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err = Load(dir)
+	if err != nil || len(cfg.Sources) != 1 || len(cfg.Projects) != 0 {
+		t.Fatalf("Unexpected configuration: %+v, err: %v", cfg, err)
+	}
+
+	if files := Files(dir); !reflect.DeepEqual(files, []string{file}) {
+		t.Errorf("Unexpected files: %v", files)
+	}
+
+	if err := os.WriteFile(path.Join(dir, "omnisync.yaml"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(dir)
+	expected := fmt.Sprintf("both %s and %s exist, remove one of them", path.Join(dir, "omnisync.yaml"), file)
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected err: %v", err)
+	}
+}
+
+// MARK: Check tests
+// Tests that the problems of a YAML file are reported at their position, and the ones of a TOML file at their path
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlFile := path.Join(dir, "omnisync.yaml")
+	data := `Settings:
+  Unmatched: {Project: Triage, Skip: true}
+Sources:
+  - Name: GitHub
+    URL: https://api.github.com/issues
+    Tgas: [github]
+  - Name: Linear
+    URL: https://api.linear.app
+    Tags: [linear]
+    Projects:
+      - URL: https://linear.app
+Projects:
+  - URL: httsp://github.com
+    OFName: GitHub
+`
+	if err := os.WriteFile(yamlFile, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []diag.Diagnostic{
+		{File: yamlFile, Line: 6, Column: 5, Severity: diag.SeverityError, Message: "unknown field `Tgas`, did you mean `Tags`?"},
+		{File: yamlFile, Line: 4, Column: 5, Severity: diag.SeverityWarning, Message: "source GitHub has no `Tags`, so every task in its projects is searched for its tasks"},
+		{File: yamlFile, Line: 7, Column: 5, Severity: diag.SeverityError, Message: "source Linear: invalid project https://linear.app: either `OFName` or `NameTemplate` is required"},
+		{File: yamlFile, Line: 13, Column: 5, Severity: diag.SeverityError, Message: "project GitHub has an invalid URL `httsp://github.com`: unknown scheme `httsp`, expected `http` or `https`"},
+		{File: yamlFile, Severity: diag.SeverityError, Message: "invalid settings: a fallback can only set one of `Project`, `Inbox` or `Skip`"},
+	}
+
+	if diags := Check(yamlFile); !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics:\n%+v\nexpected:\n%+v", diags, expected)
+	}
+
+	tomlFile := path.Join(dir, "omnisync.toml")
+	if err := os.WriteFile(tomlFile, []byte("[[Sources]]\nName = \"GitHub\"\nURL = \"https://api.github.com\"\nTgas = [\"github\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []diag.Diagnostic{
+		{File: tomlFile, Severity: diag.SeverityError, Message: "Sources[0].Tgas: unknown field `Tgas`, did you mean `Tags`?"},
+		{File: tomlFile, Severity: diag.SeverityWarning, Message: "Sources[0]: source GitHub has no `Tags`, so every task in its projects is searched for its tasks"},
+	}
+
+	if diags := Check(tomlFile); !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics:\n%+v\nexpected:\n%+v", diags, expected)
+	}
+}
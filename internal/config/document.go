@@ -0,0 +1,680 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a configuration file, which is detected from its extension
+type Format string
+
+const (
+	// FormatJSON is a JSON file, `.json`
+	FormatJSON Format = "json"
+	// FormatYAML is a YAML file, `.yaml` or `.yml`
+	FormatYAML Format = "yaml"
+	// FormatTOML is a TOML file, `.toml`
+	FormatTOML Format = "toml"
+)
+
+// extensions are the extensions of the configuration file with their format, in the order they are looked for
+var extensions = []struct {
+	ext    string
+	format Format
+}{
+	{".yaml", FormatYAML},
+	{".yml", FormatYAML},
+	{".toml", FormatTOML},
+	{".json", FormatJSON},
+}
+
+// kind is the kind of a value
+type kind int
+
+const (
+	kindScalar kind = iota
+	kindObject
+	kindList
+)
+
+// origin is where a value is in its file. The line is zero when the format doesn't keep positions, and
+// the path of the value is reported instead.
+type origin struct {
+	line   int
+	column int
+	path   string
+}
+
+// entry is a key of an object with its value
+type entry struct {
+	key    string
+	origin origin
+	value  *value
+}
+
+// value is a configuration value that keeps the order of the keys of its objects and where it is in its file,
+// so any format can be checked as if it was JSON and converted to another format without losing anything
+type value struct {
+	kind kind
+	// scalar is a string, bool, int64, float64, json.Number or nil
+	scalar  interface{}
+	entries []entry
+	items   []*value
+	origin  origin
+}
+
+// document is a configuration file converted to JSON, so it is decoded and checked like the JSON files are,
+// with the diagnostics placed back at their position in the file
+type document struct {
+	file string
+	data []byte
+	// lines holds where every line of the JSON comes from in the file, and is nil when the file is JSON
+	lines []origin
+}
+
+// yamlError matches the line of the errors of the YAML parser
+var yamlError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// tomlError matches the message of the errors of the TOML parser
+var tomlError = regexp.MustCompile(`(?s)^toml: line \d+(?: \(last key ".*?"\))?: (.*)$`)
+
+// MARK: Private helper methods
+// join returns the path of the key or index under the path
+func join(path string, key interface{}) string {
+	if i, ok := key.(int); ok {
+		return fmt.Sprintf("%s[%d]", path, i)
+	}
+
+	if path == "" {
+		return fmt.Sprint(key)
+	}
+
+	return fmt.Sprintf("%s.%s", path, key)
+}
+
+// marshal returns the JSON of the scalar, leaving characters like `<` as they are
+func marshal(x interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(x); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte{'\n'}), nil
+}
+
+// checkFloat returns an error for the floats JSON can't hold
+func checkFloat(f float64, at string) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("%s: %v can't be used in the configuration", at, f)
+	}
+
+	return nil
+}
+
+// fromYAML converts the YAML node, following aliases and merging `<<` keys
+func fromYAML(n *yaml.Node, path string) (*value, error) {
+	o := origin{line: n.Line, column: n.Column, path: path}
+	at := fmt.Sprintf("line %d", n.Line)
+
+	switch n.Kind {
+	case 0:
+		// An empty document
+		return &value{kind: kindObject, origin: o}, nil
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return &value{kind: kindObject, origin: o}, nil
+		}
+
+		return fromYAML(n.Content[0], path)
+	case yaml.AliasNode:
+		v, err := fromYAML(n.Alias, path)
+		if err != nil {
+			return nil, err
+		}
+
+		v.origin = o
+		return v, nil
+	case yaml.SequenceNode:
+		v := &value{kind: kindList, origin: o}
+		for i, item := range n.Content {
+			iv, err := fromYAML(item, join(path, i))
+			if err != nil {
+				return nil, err
+			}
+
+			v.items = append(v.items, iv)
+		}
+
+		return v, nil
+	case yaml.MappingNode:
+		v := &value{kind: kindObject, origin: o}
+		seen := map[string]bool{}
+		var merged []*value
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be strings", key.Line)
+			}
+
+			if key.ShortTag() == "!!merge" {
+				mv, err := fromYAML(val, path)
+				if err != nil {
+					return nil, err
+				}
+
+				if mv.kind == kindList {
+					merged = append(merged, mv.items...)
+				} else {
+					merged = append(merged, mv)
+				}
+				continue
+			}
+
+			vv, err := fromYAML(val, join(path, key.Value))
+			if err != nil {
+				return nil, err
+			}
+
+			seen[key.Value] = true
+			v.entries = append(v.entries, entry{key: key.Value, origin: origin{line: key.Line, column: key.Column, path: join(path, key.Value)}, value: vv})
+		}
+
+		// Merged keys never override the ones of the mapping, and the first merged mapping wins
+		for _, mv := range merged {
+			if mv.kind != kindObject {
+				return nil, fmt.Errorf("%s: only mappings can be merged", at)
+			}
+
+			for _, e := range mv.entries {
+				if !seen[e.key] {
+					seen[e.key] = true
+					v.entries = append(v.entries, e)
+				}
+			}
+		}
+
+		return v, nil
+	}
+
+	v := &value{kind: kindScalar, origin: o}
+	switch n.ShortTag() {
+	case "!!null":
+		v.scalar = nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		v.scalar = b
+	case "!!int":
+		var i int64
+		if err := n.Decode(&i); err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		v.scalar = i
+	case "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		if err := checkFloat(f, at); err != nil {
+			return nil, err
+		}
+		v.scalar = f
+	default:
+		// Timestamps and binary data are kept as they are written, since the configuration only has strings for them
+		v.scalar = n.Value
+	}
+
+	return v, nil
+}
+
+// fromTOML converts the value decoded from TOML. TOML doesn't keep positions, so every value is at its path.
+func fromTOML(x interface{}, path string) (*value, error) {
+	o := origin{path: path}
+
+	switch x := x.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		v := &value{kind: kindObject, origin: o}
+		for _, key := range keys {
+			kv, err := fromTOML(x[key], join(path, key))
+			if err != nil {
+				return nil, err
+			}
+
+			v.entries = append(v.entries, entry{key: key, origin: kv.origin, value: kv})
+		}
+
+		return v, nil
+	case []map[string]interface{}:
+		v := &value{kind: kindList, origin: o}
+		for i, item := range x {
+			iv, err := fromTOML(item, join(path, i))
+			if err != nil {
+				return nil, err
+			}
+
+			v.items = append(v.items, iv)
+		}
+
+		return v, nil
+	case []interface{}:
+		v := &value{kind: kindList, origin: o}
+		for i, item := range x {
+			iv, err := fromTOML(item, join(path, i))
+			if err != nil {
+				return nil, err
+			}
+
+			v.items = append(v.items, iv)
+		}
+
+		return v, nil
+	case float64:
+		if err := checkFloat(x, path); err != nil {
+			return nil, err
+		}
+		return &value{kind: kindScalar, scalar: x, origin: o}, nil
+	case string, bool, int64:
+		return &value{kind: kindScalar, scalar: x, origin: o}, nil
+	default:
+		// Dates and times are kept as they are written, since the configuration only has strings for them
+		return &value{kind: kindScalar, scalar: fmt.Sprint(x), origin: o}, nil
+	}
+}
+
+// fromJSON converts the JSON data, keeping the order of keys and numbers as they are written
+func fromJSON(data []byte) (*value, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := readJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top level value")
+	}
+
+	return v, nil
+}
+
+// readJSON reads the next value from the decoder
+func readJSON(dec *json.Decoder) (*value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		v := &value{kind: kindObject}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			kv, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			v.entries = append(v.entries, entry{key: key.(string), value: kv})
+		}
+
+		_, err = dec.Token()
+		return v, err
+	case json.Delim('['):
+		v := &value{kind: kindList}
+		for dec.More() {
+			iv, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			v.items = append(v.items, iv)
+		}
+
+		_, err = dec.Token()
+		return v, err
+	default:
+		return &value{kind: kindScalar, scalar: tok}, nil
+	}
+}
+
+// writeJSON writes the value as indented JSON, adding where every line comes from to the lines
+func (v *value) writeJSON(b *bytes.Buffer, lines *[]origin, indent, prefix, suffix string, o origin) error {
+	line := func(text string) {
+		b.WriteString(indent + text + "\n")
+		*lines = append(*lines, o)
+	}
+
+	switch v.kind {
+	case kindObject:
+		if len(v.entries) == 0 {
+			line(prefix + "{}" + suffix)
+			return nil
+		}
+
+		line(prefix + "{")
+		for i, e := range v.entries {
+			key, err := marshal(e.key)
+			if err != nil {
+				return err
+			}
+
+			comma := ","
+			if i == len(v.entries)-1 {
+				comma = ""
+			}
+
+			if err := e.value.writeJSON(b, lines, indent+"  ", string(key)+": ", comma, e.origin); err != nil {
+				return err
+			}
+		}
+		line("}" + suffix)
+	case kindList:
+		if len(v.items) == 0 {
+			line(prefix + "[]" + suffix)
+			return nil
+		}
+
+		line(prefix + "[")
+		for i, item := range v.items {
+			comma := ","
+			if i == len(v.items)-1 {
+				comma = ""
+			}
+
+			if err := item.writeJSON(b, lines, indent+"  ", "", comma, item.origin); err != nil {
+				return err
+			}
+		}
+		line("]" + suffix)
+	default:
+		text, err := marshal(v.scalar)
+		if err != nil {
+			return err
+		}
+
+		line(prefix + string(text) + suffix)
+	}
+
+	return nil
+}
+
+// prune returns the value without the keys of objects that hold null, an empty string, false, zero or an
+// object that is empty once pruned, which decode the same as leaving them out. Empty lists are kept, since
+// they can mean something else than a missing list.
+func (v *value) prune() *value {
+	switch v.kind {
+	case kindObject:
+		pruned := &value{kind: kindObject, origin: v.origin}
+		for _, e := range v.entries {
+			pv := e.value.prune()
+			if pv.kind == kindObject && len(pv.entries) == 0 {
+				continue
+			}
+
+			if pv.kind == kindScalar {
+				switch s := pv.scalar.(type) {
+				case nil:
+					continue
+				case string, bool:
+					if s == "" || s == false {
+						continue
+					}
+				case json.Number:
+					if f, err := s.Float64(); err == nil && f == 0 {
+						continue
+					}
+				}
+			}
+
+			pruned.entries = append(pruned.entries, entry{key: e.key, origin: e.origin, value: pv})
+		}
+		return pruned
+	case kindList:
+		pruned := &value{kind: kindList, origin: v.origin}
+		for _, item := range v.items {
+			pruned.items = append(pruned.items, item.prune())
+		}
+		return pruned
+	}
+
+	return v
+}
+
+// toYAML converts the value to a YAML node, keeping the order of keys and numbers as they are written
+func (v *value) toYAML() *yaml.Node {
+	switch v.kind {
+	case kindObject:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, e := range v.entries {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.key}, e.value.toYAML())
+		}
+		return n
+	case kindList:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v.items {
+			n.Content = append(n.Content, item.toYAML())
+		}
+		return n
+	}
+
+	switch s := v.scalar.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(s)}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(s.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: s.String()}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(s, 10)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(s, 'g', -1, 64)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(s)}
+	}
+}
+
+// toTOML converts the value to what the TOML encoder takes. TOML has no null, so null values are left out,
+// which decodes the same as long as they aren't in a list.
+func (v *value) toTOML(path string) (interface{}, error) {
+	switch v.kind {
+	case kindObject:
+		m := map[string]interface{}{}
+		for _, e := range v.entries {
+			if e.value.kind == kindScalar && e.value.scalar == nil {
+				continue
+			}
+
+			x, err := e.value.toTOML(join(path, e.key))
+			if err != nil {
+				return nil, err
+			}
+
+			m[e.key] = x
+		}
+		return m, nil
+	case kindList:
+		items := make([]interface{}, 0, len(v.items))
+		for i, item := range v.items {
+			if item.kind == kindScalar && item.scalar == nil {
+				return nil, fmt.Errorf("%s: TOML can't hold null in a list", join(path, i))
+			}
+
+			x, err := item.toTOML(join(path, i))
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, x)
+		}
+		return items, nil
+	}
+
+	if n, ok := v.scalar.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+
+		return n.Float64()
+	}
+
+	return v.scalar, nil
+}
+
+// parse converts the data of the configuration file to JSON in the format of its extension. Syntax errors are
+// returned as a diag.Diagnostic.
+func parse(file string, data []byte) (document, error) {
+	format, err := FormatOf(file)
+	if err != nil {
+		return document{}, err
+	}
+
+	var v *value
+	switch format {
+	case FormatJSON:
+		return document{file: file, data: data}, nil
+	case FormatYAML:
+		var n yaml.Node
+		if err := yaml.Unmarshal(data, &n); err != nil {
+			d := diag.Diagnostic{File: file, Severity: diag.SeverityError, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+			if m := yamlError.FindStringSubmatch(err.Error()); m != nil {
+				d.Line, _ = strconv.Atoi(m[1])
+				d.Message = m[2]
+			}
+			return document{}, d
+		}
+
+		v, err = fromYAML(&n, "")
+	case FormatTOML:
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			d := diag.Diagnostic{File: file, Severity: diag.SeverityError, Message: strings.TrimPrefix(err.Error(), "toml: ")}
+			var perr toml.ParseError
+			if errors.As(err, &perr) {
+				start := perr.Position.Start
+				if start > len(data) {
+					start = len(data)
+				}
+
+				// The line is counted from the offset, since the parser counts a newline it stopped at as the next line
+				d.Line = bytes.Count(data[:start], []byte{'\n'}) + 1
+				d.Column = utf8.RuneCount(data[bytes.LastIndexByte(data[:start], '\n')+1:start]) + 1
+				if m := tomlError.FindStringSubmatch(perr.Error()); m != nil {
+					d.Message = m[1]
+				}
+			}
+			return document{}, d
+		}
+
+		v, err = fromTOML(m, "")
+	}
+	if err != nil {
+		return document{}, diag.Diagnostic{File: file, Severity: diag.SeverityError, Message: err.Error()}
+	}
+
+	doc := document{file: file}
+	var b bytes.Buffer
+	if err := v.writeJSON(&b, &doc.lines, "", "", "", v.origin); err != nil {
+		return document{}, fmt.Errorf("failed to convert %s: %w", file, err)
+	}
+
+	doc.data = b.Bytes()
+	return doc, nil
+}
+
+// place moves the diagnostic from its position in the JSON to its position in the file
+func (doc document) place(d diag.Diagnostic) diag.Diagnostic {
+	if doc.lines == nil || d.Line == 0 {
+		return d
+	}
+
+	o := origin{}
+	if d.Line <= len(doc.lines) {
+		o = doc.lines[d.Line-1]
+	}
+
+	d.Line, d.Column = o.line, o.column
+	if o.line == 0 && o.path != "" {
+		d.Message = fmt.Sprintf("%s: %s", o.path, d.Message)
+	}
+
+	return d
+}
+
+// decode decodes the document into the value, like diag.Decode
+func (doc document) decode(v interface{}) error {
+	err := diag.Decode(doc.file, doc.data, v)
+
+	var d diag.Diagnostic
+	if errors.As(err, &d) {
+		return doc.place(d)
+	}
+
+	return err
+}
+
+// unknown returns a diagnostic for every key without a field, like diag.Unknown
+func (doc document) unknown(v interface{}) []diag.Diagnostic {
+	diags := diag.Unknown(doc.file, doc.data, v)
+	for i := range diags {
+		diags[i] = doc.place(diags[i])
+	}
+
+	return diags
+}
+
+// locate returns the problems of the elements of the list under the key as diagnostics, like diag.LocateIn
+func (doc document) locate(key string, problems []diag.Problem) []diag.Diagnostic {
+	diags := diag.LocateIn(doc.file, doc.data, key, problems)
+	for i := range diags {
+		diags[i] = doc.place(diags[i])
+	}
+
+	return diags
+}
+
+// MARK: Public methods
+// FormatOf returns the format of the configuration file from its extension
+func FormatOf(file string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range extensions {
+		if e.ext == ext {
+			return e.format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown format of %s, expected a .yaml, .yml, .toml or .json file", file)
+}
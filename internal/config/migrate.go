@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// MARK: Private helper methods
+// readJSONFile returns the value in the JSON file of the directory, or nil when the optional file doesn't exist
+func readJSONFile(dir, name string, required bool) (*value, error) {
+	file := path.Join(dir, name)
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) && !required {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	v, err := fromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	return v, nil
+}
+
+// encode writes the value in the format
+func encode(v *value, format Format) ([]byte, error) {
+	var b bytes.Buffer
+
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(v.toYAML()); err != nil {
+			return nil, err
+		}
+
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		x, err := v.toTOML("")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := toml.NewEncoder(&b).Encode(x); err != nil {
+			return nil, err
+		}
+	default:
+		var lines []origin
+		if err := v.writeJSON(&b, &lines, "", "", "", origin{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// MARK: Public methods
+// Migrate writes the configuration in the JSON files of the directory to a single configuration file, in
+// the format of its extension, and returns it. Keys keep their order and values are written as they are,
+// and the file is only written when it reads back as the same configuration, so nothing is lost. Projects
+// stay global, since nesting them under a source would stop them from routing the items of other sources.
+func Migrate(dir, file string) (Config, error) {
+	format, err := FormatOf(file)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		return Config{}, fmt.Errorf("%s already exists", file)
+	}
+
+	if existing, err := Find(dir); err != nil {
+		return Config{}, err
+	} else if existing != "" {
+		return Config{}, fmt.Errorf("the configuration in %s is already in %s", dir, existing)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	root := &value{kind: kindObject}
+	for _, f := range []struct {
+		key, name string
+		required  bool
+	}{
+		{"Settings", "settings.json", false},
+		{"Sources", "sources.json", true},
+		{"Projects", "projects.json", true},
+	} {
+		v, err := readJSONFile(dir, f.name, f.required)
+		if err != nil {
+			return Config{}, err
+		}
+
+		if v != nil {
+			root.entries = append(root.entries, entry{key: f.key, value: v})
+		}
+	}
+
+	data, err := encode(root, format)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to write the configuration as %s: %w", format, err)
+	}
+
+	migrated, err := decode(file, data)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read back the migrated configuration: %w", err)
+	}
+
+	if !reflect.DeepEqual(migrated, cfg) {
+		return Config{}, fmt.Errorf("the configuration changed when written as %s, so %s wasn't written", format, file)
+	}
+
+	// The configuration holds the headers of the sources, which are often tokens
+	err = os.WriteFile(file, data, 0600)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to write %s: %w", file, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// copyJSON copies the JSON files of the test configuration to a new directory
+func copyJSON(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		data, err := os.ReadFile(path.Join(testDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// MARK: Migrate tests
+// Tests that the JSON files are migrated to every format without changing the configuration
+func TestMigrate(t *testing.T) {
+	for _, ext := range []string{".yaml", ".yml", ".toml", ".json"} {
+		dir := copyJSON(t, "projects.json", "sources.json", "settings.json")
+
+		before, err := Load(dir)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+
+		file := path.Join(dir, FileName+ext)
+		migrated, err := Migrate(dir, file)
+		if err != nil {
+			t.Fatalf("Unexpected err migrating to %s: %s", ext, err)
+		}
+
+		if found, err := Find(dir); err != nil || found != file {
+			t.Errorf("Expected %s to be found, was: %s, err: %v", file, found, err)
+		}
+
+		after, err := Load(dir)
+		if err != nil {
+			t.Fatalf("Unexpected err loading %s: %s", ext, err)
+		}
+
+		if !reflect.DeepEqual(after, before) || !reflect.DeepEqual(migrated, before) {
+			t.Errorf("Unexpected configuration from %s:\n%+v\nexpected:\n%+v", ext, after, before)
+		}
+
+		// The empty list of headers is kept rather than dropped
+		if after.Sources[1].Headers == nil {
+			t.Errorf("Expected the empty headers to be kept in %s", ext)
+		}
+	}
+}
+
+// Tests that the keys keep their order and values are written as they are
+func TestMigrateYAML(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"sources.json":  `[{"URL": "https://example.com", "Name": "Example", "Headers": [{"Key": "Version", "Value": "2022-11-28"}], "Reopen": true, "Safety": {"MaxCompletePercent": 12.5}, "Incremental": null}]`,
+		"projects.json": `[{"URL": "https://example.com", "OFName": "true"}]`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := path.Join(dir, "omnisync.yaml")
+	if _, err := Migrate(dir, file); err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Sources:
+  - URL: https://example.com
+    Name: Example
+    Headers:
+      - Key: Version
+        Value: "2022-11-28"
+    Reopen: true
+    Safety:
+      MaxCompletePercent: 12.5
+    Incremental: null
+Projects:
+  - URL: https://example.com
+    OFName: "true"
+`
+	if string(data) != expected {
+		t.Errorf("Unexpected YAML:\n%s", data)
+	}
+
+	if _, err := Migrate(dir, file); err == nil || !strings.HasSuffix(err.Error(), "already exists") {
+		t.Errorf("Unexpected err migrating again: %v", err)
+	}
+}
+
+// Tests that nothing is written when the JSON files can't be loaded
+func TestMigrateInvalid(t *testing.T) {
+	dir := copyJSON(t, "sources.json")
+
+	file := path.Join(dir, "omnisync.yaml")
+	if _, err := Migrate(dir, file); err == nil {
+		t.Fatalf("Expected an error without projects.json")
+	}
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be written", file)
+	}
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+// Profile is a named variation of the configuration, e.g. for a work and a personal machine sharing the same
+// configuration file. A profile starts from the profile it extends, or from the configuration itself.
+type Profile struct {
+	// The name the profile is selected by
+	Name string `json:"Name"`
+	// The name of the profile this one is based on. Its fields apply unless this profile sets them
+	Extends string `json:"Extends"`
+	// The names of the sources that are synced. Leave empty to keep the ones of the extended profile, or
+	// every source when no profile sets them
+	Enabled []string `json:"Enabled"`
+	// Sources only this profile has, added to the common sources. A source with the name of a common source
+	// replaces it
+	Sources []source.Source `json:"Sources"`
+	// Projects that route the items in this profile. They are tried before the projects of the extended
+	// profile and the global projects, and win ties with them
+	Projects []project.Project `json:"Projects"`
+	// Added in front of every tag of the sources, e.g. `Work `, so the tasks of each profile stay apart.
+	// Leave empty to keep the prefix of the extended profile
+	TagPrefix string `json:"TagPrefix"`
+	// Replaces the global settings. Leave empty to keep the settings of the extended profile
+	Settings *Settings `json:"Settings"`
+}
+
+// MARK: Private helper methods
+// chain returns the profile with its extended profiles, starting with the one that extends no other
+func (cfg Config) chain(name string) ([]Profile, error) {
+	byName := map[string]Profile{}
+	for _, p := range cfg.Profiles {
+		byName[p.Name] = p
+	}
+
+	var chain []Profile
+	seen := map[string]bool{}
+	for next := name; next != ""; {
+		p, ok := byName[next]
+		if !ok && next == name {
+			return nil, fmt.Errorf("unknown profile `%s`%s", name, cfg.profileNames())
+		} else if !ok {
+			return nil, fmt.Errorf("profile %s extends unknown profile `%s`", chain[0].Name, next)
+		}
+
+		if seen[next] {
+			return nil, fmt.Errorf("profile %s extends itself", next)
+		}
+		seen[next] = true
+
+		chain = append([]Profile{p}, chain...)
+		next = p.Extends
+	}
+
+	return chain, nil
+}
+
+// profileNames returns the names of the profiles for an error message
+func (cfg Config) profileNames() string {
+	if len(cfg.Profiles) == 0 {
+		return ", the configuration has no profiles"
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for _, p := range cfg.Profiles {
+		names = append(names, p.Name)
+	}
+
+	return fmt.Sprintf(", expected one of %s", strings.Join(names, ", "))
+}
+
+// withSources returns the sources with the other sources added, replacing the ones with the same name
+func withSources(sources, others []source.Source) []source.Source {
+	merged := append([]source.Source{}, sources...)
+	for _, other := range others {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == other.Name {
+				merged[i] = other
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			merged = append(merged, other)
+		}
+	}
+
+	return merged
+}
+
+// checkProfiles returns the problems of the profiles, by their index
+func (cfg Config) checkProfiles() []diag.Problem {
+	var problems []diag.Problem
+	add := func(i int, severity diag.Severity, format string, args ...interface{}) {
+		problems = append(problems, diag.Problem{Index: i, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := map[string]int{}
+	for i, p := range cfg.Profiles {
+		if p.Name == "" {
+			add(i, diag.SeverityError, "profile without a `Name`")
+			continue
+		} else if first, ok := names[p.Name]; ok {
+			add(i, diag.SeverityError, "profile %s is also configured as profile %d, names must be unique", p.Name, first+1)
+			continue
+		}
+		names[p.Name] = i
+
+		if _, err := cfg.Resolve(p.Name); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		for _, problem := range source.Check(p.Sources) {
+			add(i, problem.Severity, "profile %s: %s", p.Name, problem.Message)
+		}
+
+		for _, problem := range project.Check(p.Projects) {
+			add(i, problem.Severity, "profile %s: %s", p.Name, problem.Message)
+		}
+
+		if p.Settings != nil {
+			for _, problem := range p.Settings.Check() {
+				add(i, problem.Severity, "profile %s: %s", p.Name, problem.Message)
+			}
+		}
+	}
+
+	return problems
+}
+
+// validateProfiles returns the first error of the profiles that stops them from being used
+func (cfg Config) validateProfiles() error {
+	for _, problem := range cfg.checkProfiles() {
+		if problem.Severity == diag.SeverityError {
+			return fmt.Errorf("invalid profile: %s", problem.Message)
+		}
+	}
+
+	return nil
+}
+
+// MARK: Public methods
+// Resolve returns the configuration of the named profile, with the profiles it extends applied in order. Without
+// a name the configuration is returned as it is, without its profiles.
+func (cfg Config) Resolve(name string) (Config, error) {
+	resolved := Config{Settings: cfg.Settings, Sources: cfg.Sources, Projects: cfg.Projects}
+	if name == "" {
+		return resolved, nil
+	}
+
+	chain, err := cfg.chain(name)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var enabled []string
+	enabledBy, prefix := "", ""
+	for _, p := range chain {
+		resolved.Sources = withSources(resolved.Sources, p.Sources)
+		resolved.Projects = append(append([]project.Project{}, p.Projects...), resolved.Projects...)
+
+		if p.Enabled != nil {
+			enabled, enabledBy = p.Enabled, p.Name
+		}
+
+		if p.TagPrefix != "" {
+			prefix = p.TagPrefix
+		}
+
+		if p.Settings != nil {
+			resolved.Settings = *p.Settings
+		}
+	}
+
+	if enabled != nil {
+		byName := map[string]bool{}
+		for _, src := range resolved.Sources {
+			byName[src.Name] = true
+		}
+
+		on := map[string]bool{}
+		for _, name := range enabled {
+			if !byName[name] {
+				return Config{}, fmt.Errorf("profile %s enables unknown source `%s`", enabledBy, name)
+			}
+			on[name] = true
+		}
+
+		var sources []source.Source
+		for _, src := range resolved.Sources {
+			if on[src.Name] {
+				sources = append(sources, src)
+			}
+		}
+		resolved.Sources = sources
+	}
+
+	if prefix != "" {
+		sources := make([]source.Source, 0, len(resolved.Sources))
+		for _, src := range resolved.Sources {
+			if src.Tags != nil {
+				tags := make([]string, 0, len(src.Tags))
+				for _, tag := range src.Tags {
+					tags = append(tags, prefix+tag)
+				}
+				src.Tags = tags
+			}
+
+			sources = append(sources, src)
+		}
+		resolved.Sources = sources
+	}
+
+	return resolved, nil
+}
+
+// Encode writes the configuration in the format, leaving out the fields that aren't set
+func (cfg Config) Encode(format Format) ([]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := fromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(v.prune(), format)
+}
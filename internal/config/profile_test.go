@@ -0,0 +1,160 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+// profiles returns a configuration with a common base, a work profile extending it and a personal profile
+func profiles() Config {
+	return Config{
+		Settings: Settings{Unmatched: project.Fallback{Project: "Triage"}},
+		Sources: []source.Source{
+			{Name: "GitHub", Tags: []string{"github"}},
+			{Name: "Jira", Tags: []string{"jira"}},
+			{Name: "Linear"},
+		},
+		Projects: []project.Project{{URL: "github.com", OFName: "GitHub"}},
+		Profiles: []Profile{
+			{Name: "base", TagPrefix: "Synced ", Projects: []project.Project{{URL: "github.com/org", OFName: "Org"}}},
+			{
+				Name:     "work",
+				Extends:  "base",
+				Enabled:  []string{"Jira", "GitHub", "Shortcut"},
+				Sources:  []source.Source{{Name: "Shortcut", URL: "https://api.app.shortcut.com", Tags: []string{"shortcut"}}, {Name: "GitHub", URL: "https://github.example.com", Tags: []string{"gh"}}},
+				Projects: []project.Project{{URL: "github.com/org/work", OFName: "Work"}},
+				Settings: &Settings{Unmatched: project.Fallback{Inbox: true}},
+			},
+			{Name: "personal", Extends: "base", Enabled: []string{"GitHub"}, TagPrefix: "Home "},
+		},
+	}
+}
+
+// MARK: Resolve tests
+// Tests that a profile applies the profiles it extends, then its own sources, projects, tag prefix and settings
+func TestResolve(t *testing.T) {
+	cfg := profiles()
+
+	work, err := cfg.Resolve("work")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := Config{
+		Settings: Settings{Unmatched: project.Fallback{Inbox: true}},
+		Sources: []source.Source{
+			{Name: "GitHub", URL: "https://github.example.com", Tags: []string{"Synced gh"}},
+			{Name: "Jira", Tags: []string{"Synced jira"}},
+			{Name: "Shortcut", URL: "https://api.app.shortcut.com", Tags: []string{"Synced shortcut"}},
+		},
+		Projects: []project.Project{
+			{URL: "github.com/org/work", OFName: "Work"},
+			{URL: "github.com/org", OFName: "Org"},
+			{URL: "github.com", OFName: "GitHub"},
+		},
+	}
+
+	if !reflect.DeepEqual(work, expected) {
+		t.Errorf("Unexpected configuration:\n%+v\nexpected:\n%+v", work, expected)
+	}
+
+	personal, err := cfg.Resolve("personal")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if len(personal.Sources) != 1 || !reflect.DeepEqual(personal.Sources[0].Tags, []string{"Home github"}) || personal.Settings.Unmatched.Project != "Triage" {
+		t.Errorf("Unexpected configuration: %+v", personal)
+	}
+
+	// The common sources are left alone
+	if cfg.Sources[0].Tags[0] != "github" {
+		t.Errorf("Unexpected tags of the common source: %v", cfg.Sources[0].Tags)
+	}
+
+	all, err := cfg.Resolve("")
+	if err != nil || len(all.Sources) != 3 || all.Profiles != nil {
+		t.Errorf("Unexpected configuration without a profile: %+v, err: %v", all, err)
+	}
+}
+
+// Tests that unknown profiles, unknown sources and profiles extending themselves are rejected
+func TestResolveErrors(t *testing.T) {
+	cfg := profiles()
+	cfg.Profiles = append(cfg.Profiles,
+		Profile{Name: "loop", Extends: "other"},
+		Profile{Name: "other", Extends: "loop"},
+		Profile{Name: "orphan", Extends: "missing"},
+		Profile{Name: "typo", Enabled: []string{"Github"}},
+	)
+
+	tests := map[string]string{
+		"nope":   "unknown profile `nope`, expected one of base, work, personal, loop, other, orphan, typo",
+		"loop":   "profile loop extends itself",
+		"orphan": "profile orphan extends unknown profile `missing`",
+		"typo":   "profile typo enables unknown source `Github`",
+	}
+
+	for name, expected := range tests {
+		if _, err := cfg.Resolve(name); err == nil || err.Error() != expected {
+			t.Errorf("Unexpected err for %s: %v", name, err)
+		}
+	}
+
+	if _, err := (Config{}).Resolve("work"); err == nil || err.Error() != "unknown profile `work`, the configuration has no profiles" {
+		t.Errorf("Unexpected err: %v", err)
+	}
+}
+
+// MARK: checkProfiles tests
+// Tests that the problems of the profiles are reported by their index
+func TestCheckProfiles(t *testing.T) {
+	cfg := profiles()
+	cfg.Profiles = append(cfg.Profiles,
+		Profile{Name: "work"},
+		Profile{},
+		Profile{Name: "broken", Extends: "missing", Projects: []project.Project{{URL: "github.com"}}},
+	)
+
+	expected := []diag.Problem{
+		{Index: 3, Severity: diag.SeverityError, Message: "profile work is also configured as profile 2, names must be unique"},
+		{Index: 4, Severity: diag.SeverityError, Message: "profile without a `Name`"},
+		{Index: 5, Severity: diag.SeverityError, Message: "profile broken extends unknown profile `missing`"},
+		{Index: 5, Severity: diag.SeverityError, Message: "profile broken: invalid project github.com: either `OFName` or `NameTemplate` is required"},
+	}
+
+	if problems := cfg.checkProfiles(); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems:\n%+v\nexpected:\n%+v", problems, expected)
+	}
+}
+
+// MARK: Encode tests
+// Tests that only the fields that are set are written, keeping empty lists
+func TestEncode(t *testing.T) {
+	cfg := Config{
+		Sources:  []source.Source{{Name: "GitHub", URL: "https://api.github.com", Tags: []string{}, Reopen: true}},
+		Projects: []project.Project{{URL: "github.com", OFName: "GitHub"}},
+	}
+
+	data, err := cfg.Encode(FormatYAML)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := `Sources:
+  - Name: GitHub
+    URL: https://api.github.com
+    Tags: []
+    Reopen: true
+Projects:
+  - URL: github.com
+    OFName: GitHub
+`
+	if string(data) != expected {
+		t.Errorf("Unexpected YAML:\n%s", data)
+	}
+}
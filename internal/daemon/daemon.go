@@ -0,0 +1,359 @@
+// Package daemon syncs the sources in the background, each on its own schedule.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/schedule"
+)
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// Job is a source that is synced on a schedule
+type Job struct {
+	Name     string
+	Schedule schedule.Schedule
+}
+
+// Target is a source to sync, or some of its items
+type Target struct {
+	Name string
+	// Records are the raw items of the source to sync, by the URL of their item, e.g. the one a webhook
+	// reported. Nil syncs the whole source
+	Records map[string]map[string]interface{}
+}
+
+// SyncFunc syncs the targets and returns the error of each source that failed, by its name
+type SyncFunc func(targets []Target) map[string]error
+
+// ReloadFunc returns the jobs of the current configuration
+type ReloadFunc func() ([]Job, error)
+
+// Daemon syncs its jobs when they are due, and the targets it is triggered with. Jobs that are due together are
+// synced in one cycle, and only one cycle runs at a time: jobs that come due while a cycle is running skip to
+// their next time, while triggered targets wait for the next cycle.
+type Daemon struct {
+	Jobs []Job
+	Sync SyncFunc
+	// Reload, when set, replaces the jobs after every cycle, so added sources and changed schedules apply without
+	// a restart
+	Reload ReloadFunc
+
+	// next holds when every job is synced next, by its name
+	next map[string]time.Time
+	// failures holds the number of failed syncs in a row of every job, by its name
+	failures map[string]int
+
+	mu sync.Mutex
+	// pending holds the triggered targets that are waiting for a cycle
+	pending []Target
+	// wake is signalled when a target is triggered
+	wake chan struct{}
+}
+
+// MARK: Private helper methods
+// schedule sets when the job is synced next, after the time or after its backoff when it is failing
+func (d *Daemon) schedule(job Job, after time.Time) {
+	next, err := job.Schedule.Next(after)
+	if err != nil {
+		// Schedules are validated when the configuration is loaded, so this only guards against a job never running again
+		log.Printf("[daemon] Invalid schedule of %s, using the default: %s", job.Name, err)
+		next, _ = schedule.Schedule{}.Next(after)
+	}
+
+	if n := d.failures[job.Name]; n > 0 {
+		if backoff := after.Add(job.Schedule.Backoff(n)); backoff.After(next) {
+			next = backoff
+		}
+	}
+
+	d.next[job.Name] = next
+}
+
+// start schedules the first sync of the job. Jobs on an interval are synced straight away and jobs on a cron
+// expression wait for their first time.
+func (d *Daemon) start(job Job, t time.Time) {
+	if job.Schedule.Cron == "" {
+		d.next[job.Name] = t
+		return
+	}
+
+	d.schedule(job, t)
+	log.Printf("[daemon] Syncing %s first at %s", job.Name, d.next[job.Name].Format(time.RFC3339))
+}
+
+// update replaces the jobs. Jobs that are new or whose schedule changed start over, and jobs that are gone are
+// forgotten.
+func (d *Daemon) update(jobs []Job, t time.Time) {
+	previous := map[string]schedule.Schedule{}
+	for _, job := range d.Jobs {
+		previous[job.Name] = job.Schedule
+	}
+
+	current := map[string]bool{}
+	for _, job := range jobs {
+		current[job.Name] = true
+		sched, ok := previous[job.Name]
+		if ok && sched == job.Schedule {
+			continue
+		}
+
+		if ok {
+			log.Printf("[daemon] The schedule of %s changed", job.Name)
+		} else {
+			log.Printf("[daemon] Now syncing %s", job.Name)
+		}
+		d.start(job, t)
+	}
+
+	for _, job := range d.Jobs {
+		if !current[job.Name] {
+			log.Printf("[daemon] No longer syncing %s", job.Name)
+			delete(d.next, job.Name)
+			delete(d.failures, job.Name)
+		}
+	}
+
+	d.Jobs = jobs
+}
+
+// reload replaces the jobs with the ones of the current configuration, keeping them when it can't be loaded
+func (d *Daemon) reload() {
+	if d.Reload == nil {
+		return
+	}
+
+	jobs, err := d.Reload()
+	if err != nil {
+		log.Printf("[daemon] Keeping the current schedules, failed to reload them: %s", err)
+		return
+	}
+
+	d.update(jobs, now())
+}
+
+// due returns the jobs whose time has come, and when the next job after them is due
+func (d *Daemon) due(t time.Time) ([]Job, time.Time) {
+	var jobs []Job
+	var earliest time.Time
+	for _, job := range d.Jobs {
+		next := d.next[job.Name]
+		if !next.After(t) {
+			jobs = append(jobs, job)
+		} else if earliest.IsZero() || next.Before(earliest) {
+			earliest = next
+		}
+	}
+
+	return jobs, earliest
+}
+
+// finish records the outcome of a cycle. Only the failures of scheduled jobs make them back off.
+func (d *Daemon) finish(jobs []Job, errs map[string]error) {
+	scheduled := map[string]bool{}
+	for _, job := range jobs {
+		scheduled[job.Name] = true
+	}
+
+	for _, name := range sortedNames(errs) {
+		if !scheduled[name] && errs[name] != nil {
+			log.Printf("[daemon] %s failed: %s", name, errs[name])
+		}
+	}
+
+	t := now()
+	for _, job := range jobs {
+		if err := errs[job.Name]; err != nil {
+			d.failures[job.Name]++
+			log.Printf("[daemon] %s failed %d times in a row: %s", job.Name, d.failures[job.Name], err)
+
+			// The job was scheduled when the cycle started, so it only needs to wait longer when it is failing
+			d.schedule(job, t)
+		} else {
+			d.failures[job.Name] = 0
+		}
+	}
+}
+
+// sortedNames returns the names of the errors in order
+func sortedNames(errs map[string]error) []string {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// merge adds the target to the targets, folding it into a target of the same source. Syncing the whole source
+// covers its items, and the latest record of an item wins.
+func merge(targets []Target, target Target) []Target {
+	for i := range targets {
+		if targets[i].Name != target.Name {
+			continue
+		}
+
+		if targets[i].Records == nil || target.Records == nil {
+			targets[i].Records = nil
+			return targets
+		}
+
+		for url, record := range target.Records {
+			targets[i].Records[url] = record
+		}
+
+		return targets
+	}
+
+	if target.Records != nil {
+		records := make(map[string]map[string]interface{}, len(target.Records))
+		for url, record := range target.Records {
+			records[url] = record
+		}
+		target.Records = records
+	}
+
+	return append(targets, target)
+}
+
+// describe returns the names of the targets, with how many items they are limited to
+func describe(targets []Target) string {
+	parts := make([]string, 0, len(targets))
+	for _, target := range targets {
+		switch n := len(target.Records); {
+		case target.Records == nil:
+			parts = append(parts, target.Name)
+		case n == 1:
+			parts = append(parts, fmt.Sprintf("%s (1 item)", target.Name))
+		default:
+			parts = append(parts, fmt.Sprintf("%s (%d items)", target.Name, n))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// wakeup returns the channel signalled when a target is triggered. The caller holds the lock.
+func (d *Daemon) wakeup() chan struct{} {
+	if d.wake == nil {
+		d.wake = make(chan struct{}, 1)
+	}
+
+	return d.wake
+}
+
+// take returns the pending targets and forgets them
+func (d *Daemon) take() []Target {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	targets := d.pending
+	d.pending = nil
+	return targets
+}
+
+// MARK: Public methods
+// Trigger syncs the target in the next cycle, straight away unless a cycle is running. It may be called from
+// any goroutine.
+func (d *Daemon) Trigger(target Target) {
+	d.mu.Lock()
+	d.pending = merge(d.pending, target)
+	wake := d.wakeup()
+	d.mu.Unlock()
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs the jobs on their schedules until the context is done. Jobs on an interval are synced straight
+// away and jobs on a cron expression wait for their first time. A running cycle is finished first when the
+// context is done, so OmniFocus is never left halfway through a sync.
+func (d *Daemon) Run(ctx context.Context) {
+	d.next = map[string]time.Time{}
+	d.failures = map[string]int{}
+
+	start := now()
+	for _, job := range d.Jobs {
+		d.start(job, start)
+	}
+
+	d.mu.Lock()
+	wake := d.wakeup()
+	d.mu.Unlock()
+
+	// running holds the scheduled jobs of the running cycle, and busy whether a cycle is running at all
+	var running []Job
+	busy := false
+	done := make(chan map[string]error, 1)
+
+	for {
+		t := now()
+		jobs, earliest := d.due(t)
+		for _, job := range jobs {
+			d.schedule(job, t)
+		}
+
+		if busy && len(jobs) > 0 {
+			var names []string
+			for _, job := range jobs {
+				names = append(names, job.Name)
+			}
+			log.Printf("[daemon] Skipping %s, the previous cycle is still running", strings.Join(names, ", "))
+		}
+
+		if !busy {
+			targets := d.take()
+			for _, job := range jobs {
+				targets = merge(targets, Target{Name: job.Name})
+			}
+
+			if len(targets) > 0 {
+				running, busy = jobs, true
+				log.Printf("[daemon] Starting a cycle of %s", describe(targets))
+
+				go func(targets []Target) {
+					done <- d.Sync(targets)
+				}(targets)
+
+				continue
+			}
+		}
+
+		wait := time.Duration(0)
+		if !earliest.IsZero() {
+			wait = earliest.Sub(t)
+		}
+
+		var timer <-chan time.Time
+		if len(d.Jobs) > 0 {
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			if busy {
+				log.Printf("[daemon] Finishing the running cycle before shutting down")
+				d.finish(running, <-done)
+			}
+
+			log.Printf("[daemon] Shut down")
+			return
+		case errs := <-done:
+			d.finish(running, errs)
+			running, busy = nil, false
+			d.reload()
+		case <-wake:
+		case <-timer:
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/schedule"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// MARK: schedule tests
+// Tests that failing jobs wait for the longer of their schedule and their backoff
+func TestSchedule(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	job := Job{Name: "GitHub", Schedule: schedule.Schedule{Every: "90s", MaxBackoff: "3m"}}
+	d := &Daemon{Jobs: []Job{job}, next: map[string]time.Time{}, failures: map[string]int{}}
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, 90 * time.Second},
+		{1, 90 * time.Second},
+		{2, 2 * time.Minute},
+		{3, 3 * time.Minute},
+		{10, 3 * time.Minute},
+	}
+
+	for _, test := range tests {
+		d.failures[job.Name] = test.failures
+		d.schedule(job, start)
+		if got := d.next[job.Name].Sub(start); got != test.expected {
+			t.Errorf("Expected a wait of %s after %d failures, was: %s", test.expected, test.failures, got)
+		}
+	}
+}
+
+// Tests that reloading starts new jobs and jobs with a changed schedule over, keeps the others and forgets
+// removed ones
+func TestUpdate(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := start.Add(time.Hour)
+	d := &Daemon{
+		Jobs: []Job{
+			{Name: "GitHub", Schedule: schedule.Schedule{Every: "10m"}},
+			{Name: "Jira", Schedule: schedule.Schedule{Every: "10m"}},
+			{Name: "Linear", Schedule: schedule.Schedule{Every: "10m"}},
+		},
+		next:     map[string]time.Time{"GitHub": start, "Jira": start, "Linear": start},
+		failures: map[string]int{"Jira": 2, "Linear": 1},
+	}
+
+	d.update([]Job{
+		{Name: "GitHub", Schedule: schedule.Schedule{Every: "10m"}},
+		{Name: "Jira", Schedule: schedule.Schedule{Cron: "0 3 * * *"}},
+		{Name: "Todoist", Schedule: schedule.Schedule{Every: "5m"}},
+	}, later)
+
+	if _, ok := d.next["Linear"]; ok || !d.next["GitHub"].Equal(start) || !d.next["Todoist"].Equal(later) {
+		t.Errorf("Unexpected next syncs: %v", d.next)
+	}
+
+	// Jira keeps backing off on its new schedule
+	if !d.next["Jira"].After(later) || d.failures["Jira"] != 2 {
+		t.Errorf("Expected Jira to be rescheduled, next syncs: %v, failures: %v", d.next, d.failures)
+	}
+
+	if _, ok := d.failures["Linear"]; ok || len(d.Jobs) != 3 {
+		t.Errorf("Expected Linear to be forgotten, jobs: %v, failures: %v", d.Jobs, d.failures)
+	}
+}
+
+// MARK: Run tests
+// Tests that jobs due together share a cycle, that cycles never overlap, that failures are counted and that
+// shutting down waits for the running cycle
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	var cycles [][]string
+	running, overlapped, finished := false, false, false
+
+	d := &Daemon{
+		Jobs: []Job{
+			{Name: "GitHub", Schedule: schedule.Schedule{Every: "10ms"}},
+			{Name: "Jira", Schedule: schedule.Schedule{Every: "10ms"}},
+			{Name: "Nightly", Schedule: schedule.Schedule{Cron: "0 3 * * *"}},
+		},
+		Sync: func(targets []Target) map[string]error {
+			mu.Lock()
+			overlapped = overlapped || running
+			running = true
+			cycles = append(cycles, targetNames(targets))
+			mu.Unlock()
+
+			// Longer than the interval, so the jobs come due while the cycle is running
+			time.Sleep(35 * time.Millisecond)
+
+			mu.Lock()
+			running = false
+			finished = true
+			mu.Unlock()
+
+			return map[string]error{"Jira": fmt.Errorf("unauthorized")}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(cycles) == 0 || !reflect.DeepEqual(cycles[0], []string{"GitHub", "Jira"}) {
+		t.Fatalf("Unexpected cycles: %v", cycles)
+	}
+
+	if len(cycles) > 2 || overlapped || running || !finished {
+		t.Errorf("Expected at most two cycles that don't overlap and finish before shutting down, was: %v", cycles)
+	}
+
+	if d.failures["Jira"] == 0 || d.failures["GitHub"] != 0 {
+		t.Errorf("Unexpected failures: %v", d.failures)
+	}
+
+	// Backing off, Jira waits at least a minute while GitHub keeps its interval
+	if d.next["Jira"].Sub(d.next["GitHub"]) < 50*time.Second {
+		t.Errorf("Expected Jira to back off, next syncs: %v", d.next)
+	}
+}
+
+// targetNames returns the names of the targets
+func targetNames(targets []Target) []string {
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+
+	return names
+}
+
+// Tests that targets triggered while a cycle is running wait for the next one, folded together by source
+func TestTrigger(t *testing.T) {
+	issue := func(n int) map[string]interface{} {
+		return map[string]interface{}{"number": float64(n)}
+	}
+
+	var mu sync.Mutex
+	var cycles [][]Target
+	started := make(chan struct{})
+
+	d := &Daemon{
+		Sync: func(targets []Target) map[string]error {
+			mu.Lock()
+			cycles = append(cycles, targets)
+			first := len(cycles) == 1
+			mu.Unlock()
+
+			if first {
+				close(started)
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			return map[string]error{"Jira": fmt.Errorf("unauthorized")}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(stopped)
+	}()
+
+	d.Trigger(Target{Name: "GitHub", Records: map[string]map[string]interface{}{"/issues/1": issue(1)}})
+	<-started
+
+	// Queued while the first cycle is running
+	d.Trigger(Target{Name: "GitHub", Records: map[string]map[string]interface{}{"/issues/2": issue(2)}})
+	d.Trigger(Target{Name: "GitHub", Records: map[string]map[string]interface{}{"/issues/2": issue(3)}})
+	d.Trigger(Target{Name: "Jira"})
+	d.Trigger(Target{Name: "Jira", Records: map[string]map[string]interface{}{"/browse/X-1": issue(4)}})
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-stopped
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := [][]Target{
+		{{Name: "GitHub", Records: map[string]map[string]interface{}{"/issues/1": issue(1)}}},
+		{{Name: "GitHub", Records: map[string]map[string]interface{}{"/issues/2": issue(3)}}, {Name: "Jira"}},
+	}
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Unexpected cycles: %v", cycles)
+	}
+
+	// Triggered syncs don't make sources back off
+	if len(d.failures) != 0 {
+		t.Errorf("Unexpected failures: %v", d.failures)
+	}
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// Label names the launchd agent
+	Label = "com.github.trevorpiltch.omnisync"
+	// Unit names the systemd user unit
+	Unit = "omnisync.service"
+)
+
+// Init is a service manager the daemon can be installed with
+type Init string
+
+const (
+	InitLaunchd Init = "launchd"
+	InitSystemd Init = "systemd"
+)
+
+// Service is how the service manager starts the daemon
+type Service struct {
+	// The absolute path of the omnisync binary
+	Executable string
+	// The arguments the binary is started with, e.g. `--config-dir <dir> daemon`
+	Args []string
+	// The file the output of the daemon is appended to. Only used by launchd, systemd keeps it in the journal
+	LogFile string
+}
+
+// MARK: Private helper methods
+// escapeXML escapes the text for a plist
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// quoteSystemd quotes the argument for an `ExecStart` line when it holds spaces, quotes or backslashes, and
+// escapes the `%` specifiers systemd would otherwise expand
+func quoteSystemd(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
+
+// MARK: Public methods
+// ParseInit returns the service manager with the given name
+func ParseInit(name string) (Init, error) {
+	switch system := Init(name); system {
+	case InitLaunchd, InitSystemd:
+		return system, nil
+	default:
+		return "", fmt.Errorf("unknown init system `%s`, expected launchd or systemd", name)
+	}
+}
+
+// Path returns where the service file of the init system is installed for the user with the given home directory
+func (system Init) Path(home string) string {
+	if system == InitLaunchd {
+		return path.Join(home, "Library", "LaunchAgents", Label+".plist")
+	}
+
+	return path.Join(home, ".config", "systemd", "user", Unit)
+}
+
+// Render returns the service file that starts the daemon with the init system
+func (system Init) Render(service Service) string {
+	if system == InitLaunchd {
+		return service.Launchd()
+	}
+
+	return service.Systemd()
+}
+
+// Enable returns the command that starts the installed service and keeps it running across logins
+func (system Init) Enable(home string) string {
+	if system == InitLaunchd {
+		return "launchctl load -w " + system.Path(home)
+	}
+
+	return "systemctl --user enable --now " + Unit
+}
+
+// Launchd returns a launchd agent that starts the daemon at login and restarts it when it exits
+func (service Service) Launchd() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", Label)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{service.Executable}, service.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", escapeXML(arg))
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	if service.LogFile != "" {
+		fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", escapeXML(service.LogFile))
+		fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", escapeXML(service.LogFile))
+	}
+	b.WriteString("</dict>\n</plist>\n")
+
+	return b.String()
+}
+
+// Systemd returns a systemd user unit that starts the daemon at login and restarts it when it fails
+func (service Service) Systemd() string {
+	args := make([]string, 0, len(service.Args)+1)
+	for _, arg := range append([]string{service.Executable}, service.Args...) {
+		args = append(args, quoteSystemd(arg))
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=OmniSync daemon
+After=network-online.target
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=default.target
+`, strings.Join(args, " "))
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+// MARK: Install tests
+// Tests that the arguments of the daemon are escaped for both init systems
+func TestRender(t *testing.T) {
+	service := Service{
+		Executable: "/usr/local/bin/omnisync",
+		Args:       []string{"--config-dir", "/Users/me/My Config", "--profile", "R&D", "daemon"},
+		LogFile:    "/Users/me/Library/Logs/omnisync.log",
+	}
+
+	launchd := InitLaunchd.Render(service)
+	for _, expected := range []string{
+		"<string>" + Label + "</string>",
+		"\t\t<string>/usr/local/bin/omnisync</string>\n\t\t<string>--config-dir</string>\n\t\t<string>/Users/me/My Config</string>\n",
+		"<string>R&amp;D</string>",
+		"<key>StandardErrorPath</key>\n\t<string>/Users/me/Library/Logs/omnisync.log</string>",
+	} {
+		if !strings.Contains(launchd, expected) {
+			t.Errorf("Expected the plist to contain %q, was:\n%s", expected, launchd)
+		}
+	}
+
+	systemd := InitSystemd.Render(service)
+	expected := `ExecStart=/usr/local/bin/omnisync --config-dir "/Users/me/My Config" --profile R&D daemon` + "\n"
+	if !strings.Contains(systemd, expected) {
+		t.Errorf("Expected the unit to contain %q, was:\n%s", expected, systemd)
+	}
+}
+
+// Tests that systemd specifiers and quotes are escaped
+func TestQuoteSystemd(t *testing.T) {
+	tests := []struct {
+		arg, expected string
+	}{
+		{"daemon", "daemon"},
+		{"", `""`},
+		{"100%", "100%%"},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir`, `"C:\\dir"`},
+	}
+
+	for _, test := range tests {
+		if got := quoteSystemd(test.arg); got != test.expected {
+			t.Errorf("Expected %q to be quoted as %s, was: %s", test.arg, test.expected, got)
+		}
+	}
+}
+
+// Tests that the init system is looked up by its name
+func TestParseInit(t *testing.T) {
+	if got, err := ParseInit("systemd"); err != nil || got != InitSystemd {
+		t.Errorf("Unexpected init system: %s, %v", got, err)
+	}
+
+	if _, err := ParseInit("upstart"); err == nil || err.Error() != "unknown init system `upstart`, expected launchd or systemd" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if got := InitLaunchd.Path("/Users/me"); got != "/Users/me/Library/LaunchAgents/"+Label+".plist" {
+		t.Errorf("Unexpected launchd path: %s", got)
+	}
+}
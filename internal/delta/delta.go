@@ -35,11 +35,13 @@ type OperationType int
 const (
 	Add OperationType = iota + 1
 	Remove
+	Complete
+	Reopen
 )
 
 func (op OperationType) String() string {
-	ops := [...]string{"add", "remove"}
-	if op < Add || op > Remove {
+	ops := [...]string{"add", "remove", "complete", "reopen"}
+	if op < Add || op > Reopen {
 		return fmt.Sprintf("DeltaOperation(%d)", int(op))
 	}
 	return ops[op-1]
@@ -51,14 +53,32 @@ type Keyed interface {
 	Key() string
 }
 
+// Closable is implemented by items that can be closed. A closed desired item
+// is never added, and the matching open current item gets a Complete
+// operation rather than being left alone or removed. A closed current item
+// is never removed, and gets a Reopen operation if desired has it open.
+type Closable interface {
+	IsClosed() bool
+}
+
 // A Operation states that Item should be added or removed from a set.
 type Operation struct {
 	Item Keyed
 	Type OperationType
+	// Desired is the desired item that caused a Complete or Reopen operation
+	// on the current Item.
+	Desired Keyed
+}
+
+// isClosed returns whether the item is Closable and closed.
+func isClosed(k Keyed) bool {
+	c, ok := k.(Closable)
+	return ok && c.IsClosed()
 }
 
 // Delta returns a slice of DeltaOperations that, when applied to current,
-// will result in current containing the same items as desired.
+// will result in the open items of current being the same as the open items
+// of desired.
 func Delta(desired, current map[Keyed]struct{}) []Operation {
 	ops := []Operation{}
 
@@ -80,18 +100,36 @@ func Delta(desired, current map[Keyed]struct{}) []Operation {
 	}
 
 	// If it's in desired, and not in current: add it.
+	// If it's closed in desired, and open in current: complete it.
+	// If it's open in desired, and closed in current: reopen it.
 	for k, v := range desired2 {
-		if _, ok := current2[k]; !ok {
+		c, ok := current2[k]
+		switch {
+		case isClosed(v):
+			if ok && !isClosed(c) {
+				ops = append(ops, Operation{
+					Type:    Complete,
+					Item:    c,
+					Desired: v,
+				})
+			}
+		case !ok:
 			ops = append(ops, Operation{
 				Type: Add,
 				Item: v,
 			})
+		case isClosed(c):
+			ops = append(ops, Operation{
+				Type:    Reopen,
+				Item:    c,
+				Desired: v,
+			})
 		}
 	}
 
-	// If it's in current, and not in desired: remove it.
+	// If it's open in current, and not in desired: remove it.
 	for k, v := range current2 {
-		if _, ok := desired2[k]; !ok {
+		if _, ok := desired2[k]; !ok && !isClosed(v) {
 			ops = append(ops, Operation{
 				Type: Remove,
 				Item: v,
@@ -0,0 +1,72 @@
+package delta
+
+import (
+	"testing"
+)
+
+// closable is a desired item that can be reported closed
+type closable struct {
+	key    string
+	closed bool
+}
+
+func (c closable) Key() string {
+	return c.key
+}
+
+func (c closable) IsClosed() bool {
+	return c.closed
+}
+
+func set(items ...Keyed) map[Keyed]struct{} {
+	r := map[Keyed]struct{}{}
+	for _, i := range items {
+		r[i] = struct{}{}
+	}
+	return r
+}
+
+// opsByKey indexes operations by the key of their item
+func opsByKey(ops []Operation) map[string]OperationType {
+	r := map[string]OperationType{}
+	for _, op := range ops {
+		r[op.Item.Key()] = op.Type
+	}
+	return r
+}
+
+// MARK: Delta tests
+func TestDelta(t *testing.T) {
+	desired := set(
+		closable{key: "new"},
+		closable{key: "kept"},
+		closable{key: "closed", closed: true},
+		closable{key: "gone", closed: true},
+		closable{key: "reopened"},
+	)
+	current := set(
+		key("kept"),
+		key("closed"),
+		key("vanished"),
+		closable{key: "reopened", closed: true},
+		closable{key: "done", closed: true},
+	)
+
+	ops := opsByKey(Delta(desired, current))
+	expected := map[string]OperationType{
+		"new":      Add,
+		"closed":   Complete,
+		"vanished": Remove,
+		"reopened": Reopen,
+	}
+
+	if len(ops) != len(expected) {
+		t.Fatalf("Unexpected operations: %v", ops)
+	}
+
+	for k, op := range expected {
+		if ops[k] != op {
+			t.Fatalf("Expected %s for %s, was %s", op, k, ops[k])
+		}
+	}
+}
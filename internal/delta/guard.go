@@ -0,0 +1,65 @@
+package delta
+
+import (
+	"fmt"
+)
+
+// minGuardedRemovals is the size of the lists the percentage limit never applies to, so that closing the
+// last few items of a small list doesn't trip it.
+const minGuardedRemovals = 3
+
+// Limits caps how many of the current items a single delta is allowed to remove.
+type Limits struct {
+	// MaxRemove is the maximum number of items that may be removed. Zero means no limit.
+	MaxRemove int
+	// MaxRemovePercent is the maximum percentage of current items that may be removed. Zero means no limit.
+	MaxRemovePercent float64
+}
+
+// Count returns the number of operations of the given type.
+func Count(ops []Operation, t OperationType) int {
+	n := 0
+	for _, op := range ops {
+		if op.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// Check returns an error when ops would remove or complete more of the
+// current items than the limits allow.
+func (l Limits) Check(ops []Operation, current int) error {
+	removals := Count(ops, Remove) + Count(ops, Complete)
+	if removals == 0 {
+		return nil
+	}
+
+	if l.MaxRemove > 0 && removals > l.MaxRemove {
+		return fmt.Errorf("refusing to remove %d of %d items, the limit is %d", removals, current, l.MaxRemove)
+	}
+
+	if l.MaxRemovePercent > 0 && current > minGuardedRemovals {
+		percent := float64(removals) / float64(current) * 100
+		if percent > l.MaxRemovePercent {
+			return fmt.Errorf("refusing to remove %d of %d items (%.0f%%), the limit is %.0f%%", removals, current, percent, l.MaxRemovePercent)
+		}
+	}
+
+	return nil
+}
+
+// Without returns ops with every operation of the given types left out.
+func Without(ops []Operation, types ...OperationType) []Operation {
+	r := []Operation{}
+outer:
+	for _, op := range ops {
+		for _, t := range types {
+			if op.Type == t {
+				continue outer
+			}
+		}
+		r = append(r, op)
+	}
+	return r
+}
@@ -0,0 +1,72 @@
+package delta
+
+import (
+	"testing"
+)
+
+// key is a minimal Keyed implementation used in testing
+type key string
+
+func (k key) Key() string {
+	return string(k)
+}
+
+// removals returns n remove operations
+func removals(n int) []Operation {
+	ops := []Operation{{Type: Add, Item: key("added")}}
+	for i := 0; i < n; i++ {
+		ops = append(ops, Operation{Type: Remove, Item: key(string(rune('a' + i)))})
+	}
+	return ops
+}
+
+// MARK: Limits tests
+func TestLimitsMaxRemove(t *testing.T) {
+	limits := Limits{MaxRemove: 2}
+
+	if err := limits.Check(removals(2), 10); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err := limits.Check(removals(3), 10)
+	if err == nil || err.Error() != "refusing to remove 3 of 10 items, the limit is 2" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestLimitsMaxRemovePercent(t *testing.T) {
+	limits := Limits{MaxRemovePercent: 50}
+
+	if err := limits.Check(removals(5), 10); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err := limits.Check(removals(6), 10)
+	if err == nil || err.Error() != "refusing to remove 6 of 10 items (60%), the limit is 50%" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Small lists can always be emptied
+	if err := limits.Check(removals(3), 3); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// but a few removals from a longer list are still limited
+	err = limits.Check(removals(3), 4)
+	if err == nil || err.Error() != "refusing to remove 3 of 4 items (75%), the limit is 50%" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestLimitsNone(t *testing.T) {
+	if err := (Limits{}).Check(removals(20), 20); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func TestWithout(t *testing.T) {
+	ops := Without(removals(4), Remove)
+	if len(ops) != 1 || ops[0].Type != Add {
+		t.Fatalf("Unexpected operations: %v", ops)
+	}
+}
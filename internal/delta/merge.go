@@ -0,0 +1,77 @@
+package delta
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ownership says which side wins when a field was changed both upstream and locally since it was last synced.
+type Ownership string
+
+const (
+	// UpstreamWins replaces the local value with the upstream one.
+	UpstreamWins Ownership = "upstream"
+	// LocalWins keeps the local value.
+	LocalWins Ownership = "local"
+	// Append keeps the upstream lines followed by the lines that were added locally.
+	Append Ownership = "append"
+)
+
+// Validate returns an error for an unknown ownership.
+func (o Ownership) Validate() error {
+	switch o {
+	case "", UpstreamWins, LocalWins, Append:
+		return nil
+	default:
+		return fmt.Errorf("unknown ownership `%s`", o)
+	}
+}
+
+// Merge returns the value of a field after a three-way merge of the value it
+// was last synced with, its value upstream and its value locally. A side that
+// didn't change since the last sync never overrides the other one, so local
+// edits are kept for as long as the field doesn't change upstream. When both
+// sides changed, the ownership decides.
+func Merge(base, upstream, local string, owner Ownership) string {
+	switch {
+	case upstream == local, upstream == base:
+		return local
+	case local == base:
+		return upstream
+	}
+
+	switch owner {
+	case LocalWins:
+		return local
+	case Append:
+		return appendLines(base, upstream, local)
+	default:
+		return upstream
+	}
+}
+
+// appendLines returns the upstream lines followed by the local lines that
+// are neither in the base nor upstream.
+func appendLines(base, upstream, local string) string {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(base, "\n") {
+		seen[line] = true
+	}
+
+	lines := []string{}
+	if upstream != "" {
+		lines = strings.Split(upstream, "\n")
+	}
+	for _, line := range lines {
+		seen[line] = true
+	}
+
+	for _, line := range strings.Split(local, "\n") {
+		if !seen[line] {
+			lines = append(lines, line)
+			seen[line] = true
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package delta
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name                  string
+		base, upstream, local string
+		owner                 Ownership
+		expected              string
+	}{
+		{"unchanged", "a", "a", "a", UpstreamWins, "a"},
+		{"local edit is kept", "a", "a", "mine", UpstreamWins, "mine"},
+		{"upstream edit is applied", "a", "theirs", "a", LocalWins, "theirs"},
+		{"same edit on both sides", "a", "b", "b", LocalWins, "b"},
+		{"conflict, upstream wins", "a", "theirs", "mine", UpstreamWins, "theirs"},
+		{"conflict, default is upstream", "a", "theirs", "mine", "", "theirs"},
+		{"conflict, local wins", "a", "theirs", "mine", LocalWins, "mine"},
+		{"conflict, append", "url", "new url", "url\nmy note", Append, "new url\nmy note"},
+		{"conflict, append keeps local lines once", "a\nb", "a\nc", "a\nb\nd\nc", Append, "a\nc\nd"},
+	}
+
+	for _, tt := range tests {
+		actual := Merge(tt.base, tt.upstream, tt.local, tt.owner)
+		if actual != tt.expected {
+			t.Errorf("%s: expected %q, was %q", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestOwnershipValidate(t *testing.T) {
+	if err := Append.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err := Ownership("mine").Validate()
+	if err == nil || err.Error() != "unknown ownership `mine`" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,401 @@
+// Package diag reports problems in the configuration files with the line and column they are at, so they
+// can be fixed without hunting for them.
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity says whether a problem stops the configuration from being used
+type Severity string
+
+const (
+	// SeverityError is a problem that stops the configuration from being used
+	SeverityError Severity = "error"
+	// SeverityWarning is a problem that is likely a mistake, but doesn't stop the configuration from being used
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem at a position in a configuration file
+type Diagnostic struct {
+	File string `json:"file"`
+	// Line and Column are 1-based, and zero when the problem isn't at a single position
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Problem is a problem with an element of a list in a configuration file, found after decoding it
+type Problem struct {
+	// Index is the position of the element in the list, or -1 for the whole file
+	Index    int
+	Severity Severity
+	Message  string
+}
+
+// field is a field of a struct as it is named in JSON
+type field struct {
+	name string
+	typ  reflect.Type
+}
+
+// unmarshaler is the type of values that decode themselves
+var unmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// MARK: Private helper methods
+// position returns the line and column of the byte offset in the data
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := data[:offset]
+	start := bytes.LastIndexByte(before, '\n') + 1
+	return bytes.Count(before, []byte{'\n'}) + 1, utf8.RuneCount(before[start:]) + 1
+}
+
+// skipSpace returns the offset of the first byte at or after the offset that is neither whitespace nor a
+// separator, which is where the next value starts
+func skipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\n', '\r', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+// fields returns the fields of the struct type as they are named in JSON, including the ones of embedded structs
+func fields(t reflect.Type) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fs = append(fs, fields(ft)...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fs = append(fs, field{name: name, typ: f.Type})
+	}
+
+	return fs
+}
+
+// fieldName returns the dotted path of a field without the indexes of the lists it is in, which the
+// position already points at
+func fieldName(path string) string {
+	var names []string
+	for _, name := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(name); err != nil && name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, ".")
+}
+
+// distance returns the number of single character edits between the strings, ignoring case
+func distance(a, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			next := prev + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j-1]+1 < next {
+				next = row[j-1] + 1
+			}
+
+			prev, row[j] = row[j], next
+		}
+	}
+
+	return row[len(rb)]
+}
+
+// suggest returns the known field closest to the unknown one, or an empty string when none is close
+func suggest(name string, fs []field) string {
+	// Longer names are allowed more typos
+	best, bestDistance := "", len(name)/3+2
+	for _, f := range fs {
+		if d := distance(name, f.name); d < bestDistance {
+			best, bestDistance = f.name, d
+		}
+	}
+
+	return best
+}
+
+// walker walks the tokens of a JSON document along the Go type it decodes into
+type walker struct {
+	file  string
+	data  []byte
+	dec   *json.Decoder
+	diags []Diagnostic
+}
+
+// value walks the next value, checking the fields of objects that decode into structs. A nil type accepts
+// any value.
+func (w *walker) value(t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(unmarshaler)) {
+		t = nil
+	}
+
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '[':
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+
+		for w.dec.More() {
+			if err := w.value(elem); err != nil {
+				return err
+			}
+		}
+	case '{':
+		for w.dec.More() {
+			start := skipSpace(w.data, w.dec.InputOffset())
+			tok, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+
+			key, _ := tok.(string)
+			var elem reflect.Type
+			switch {
+			case t != nil && t.Kind() == reflect.Struct:
+				elem, ok = w.field(t, key, start)
+				if !ok {
+					elem = nil
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				elem = t.Elem()
+			}
+
+			if err := w.value(elem); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The closing delimiter
+	_, err = w.dec.Token()
+	return err
+}
+
+// find moves the walker to the value of the key of the object at the top of the document, matching it
+// regardless of case like decoding does, and returns whether it is there
+func (w *walker) find(key string) bool {
+	if tok, err := w.dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+
+	for w.dec.More() {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return false
+		}
+
+		if name, _ := tok.(string); strings.EqualFold(name, key) {
+			return true
+		}
+
+		if err := w.value(nil); err != nil {
+			return false
+		}
+	}
+
+	return false
+}
+
+// field returns the type of the field of the struct type with the given key, and reports the key when the
+// struct has no such field. Keys match fields regardless of case, like they do when decoding.
+func (w *walker) field(t reflect.Type, key string, offset int64) (reflect.Type, bool) {
+	fs := fields(t)
+	for _, f := range fs {
+		if f.name == key {
+			return f.typ, true
+		}
+	}
+
+	for _, f := range fs {
+		if strings.EqualFold(f.name, key) {
+			return f.typ, true
+		}
+	}
+
+	message := fmt.Sprintf("unknown field `%s`", key)
+	if s := suggest(key, fs); s != "" {
+		message += fmt.Sprintf(", did you mean `%s`?", s)
+	}
+
+	line, column := position(w.data, offset)
+	w.diags = append(w.diags, Diagnostic{File: w.file, Line: line, Column: column, Severity: SeverityError, Message: message})
+	return nil, false
+}
+
+// MARK: Public methods
+// Error returns the diagnostic as `file:line:column: message`
+func (d Diagnostic) Error() string {
+	var b strings.Builder
+	b.WriteString(d.File)
+	if d.Line > 0 {
+		fmt.Fprintf(&b, ":%d", d.Line)
+	}
+	if d.Line > 0 && d.Column > 0 {
+		fmt.Fprintf(&b, ":%d", d.Column)
+	}
+	if d.Severity == SeverityWarning {
+		b.WriteString(": warning")
+	}
+	fmt.Fprintf(&b, ": %s", d.Message)
+	return b.String()
+}
+
+// Decode decodes the JSON data of the file into the value. Syntax errors and values of the wrong type are
+// returned as a Diagnostic with their position.
+func Decode(file string, data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	var offset int64
+	message := err.Error()
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is after the character that couldn't be read
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if name := fieldName(typeErr.Field); name != "" {
+			message = fmt.Sprintf("`%s` can't be a JSON %s, expected a %s", name, typeErr.Value, typeErr.Type)
+		} else {
+			message = fmt.Sprintf("expected a %s, not a JSON %s", typeErr.Type, typeErr.Value)
+		}
+	default:
+		return Diagnostic{File: file, Severity: SeverityError, Message: message}
+	}
+
+	line, column := position(data, offset)
+	return Diagnostic{File: file, Line: line, Column: column, Severity: SeverityError, Message: strings.TrimPrefix(message, "json: ")}
+}
+
+// Unknown returns a diagnostic for every key of the JSON data of the file that doesn't match a field of the
+// struct it decodes into, which would otherwise be silently ignored
+func Unknown(file string, data []byte, v interface{}) []Diagnostic {
+	w := &walker{file: file, data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+
+	// Syntax errors are reported by Decode, so the walk just stops at them
+	_ = w.value(reflect.TypeOf(v))
+
+	return w.diags
+}
+
+// Locate returns the problems found in the elements of the JSON list of the file as diagnostics at the
+// position of their element
+func Locate(file string, data []byte, problems []Problem) []Diagnostic {
+	return LocateIn(file, data, "", problems)
+}
+
+// LocateIn is Locate for a list under a key of the JSON object of the file. An empty key is the whole file.
+func LocateIn(file string, data []byte, key string, problems []Problem) []Diagnostic {
+	var offsets []int64
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	w := &walker{file: file, data: data, dec: dec}
+
+	// Without the key, the problems are reported for the whole file
+	if key == "" || w.find(key) {
+		if tok, err := dec.Token(); err == nil && tok == json.Delim('[') {
+			for dec.More() {
+				offsets = append(offsets, skipSpace(data, dec.InputOffset()))
+				if err := w.value(nil); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	diags := make([]Diagnostic, 0, len(problems))
+	for _, p := range problems {
+		d := Diagnostic{File: file, Severity: p.Severity, Message: p.Message}
+		if p.Index >= 0 && p.Index < len(offsets) {
+			d.Line, d.Column = position(data, offsets[p.Index])
+		}
+
+		diags = append(diags, d)
+	}
+
+	return diags
+}
+
+// HasErrors returns whether any of the diagnostics is an error
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package diag
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type rule struct {
+	Field string
+	Value string
+}
+
+type item struct {
+	Title  string
+	URL    string `json:"url"`
+	Rules  []rule
+	Extra  map[string]rule
+	Fields map[string]interface{}
+	hidden string
+}
+
+// MARK: Decode tests
+// Tests that syntax errors and values of the wrong type are reported at their line and column
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		data     string
+		line     int
+		column   int
+		expected string
+	}{
+		{"[\n  {\"Title\": \"one\"}\n  {\"Title\": \"two\"}\n]", 3, 3, "invalid character '{' after array element"},
+		{"[\n  {\"Title\": 1}\n]", 2, 14, "`Title` can't be a JSON number, expected a string"},
+		{"{}", 1, 2, "expected a []diag.item, not a JSON object"},
+	}
+
+	for _, test := range tests {
+		var items []item
+		err := Decode("items.json", []byte(test.data), &items)
+
+		var d Diagnostic
+		if !errors.As(err, &d) {
+			t.Fatalf("Expected a diagnostic for %q, was: %v", test.data, err)
+		}
+
+		if d.Line != test.line || d.Column != test.column || d.Message != test.expected || d.File != "items.json" {
+			t.Errorf("Unexpected diagnostic for %q: %+v", test.data, d)
+		}
+	}
+
+	var items []item
+	if err := Decode("items.json", []byte(`[{"Title": "one"}]`), &items); err != nil || items[0].Title != "one" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// MARK: Unknown tests
+// Tests that keys without a field are reported with a suggestion, through lists and maps, ignoring case
+func TestUnknown(t *testing.T) {
+	data := `[
+  {
+    "Titel": "one",
+    "URL": "https://example.com",
+    "Rules": [{"Field": "state", "Vaule": "open"}],
+    "Extra": {"a": {"field": "x", "Other": 1}},
+    "Fields": {"anything": {"goes": true}},
+    "hidden": "x",
+    "Zzzzzz": 1
+  }
+]`
+
+	var items []item
+	diags := Unknown("items.json", []byte(data), &items)
+
+	expected := []Diagnostic{
+		{File: "items.json", Line: 3, Column: 5, Severity: SeverityError, Message: "unknown field `Titel`, did you mean `Title`?"},
+		{File: "items.json", Line: 5, Column: 34, Severity: SeverityError, Message: "unknown field `Vaule`, did you mean `Value`?"},
+		{File: "items.json", Line: 6, Column: 35, Severity: SeverityError, Message: "unknown field `Other`"},
+		{File: "items.json", Line: 8, Column: 5, Severity: SeverityError, Message: "unknown field `hidden`"},
+		{File: "items.json", Line: 9, Column: 5, Severity: SeverityError, Message: "unknown field `Zzzzzz`"},
+	}
+
+	if !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics:\n%+v\nexpected:\n%+v", diags, expected)
+	}
+}
+
+// MARK: Locate tests
+// Tests that problems are reported at the position of their element
+func TestLocate(t *testing.T) {
+	data := "[\n  {\"Title\": \"one\"},\n\n    {\"Title\": \"two\"}\n]"
+
+	diags := Locate("items.json", []byte(data), []Problem{
+		{Index: 1, Severity: SeverityWarning, Message: "second"},
+		{Index: -1, Severity: SeverityError, Message: "file"},
+	})
+
+	expected := []Diagnostic{
+		{File: "items.json", Line: 4, Column: 5, Severity: SeverityWarning, Message: "second"},
+		{File: "items.json", Severity: SeverityError, Message: "file"},
+	}
+
+	if !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics: %+v", diags)
+	}
+
+	if !HasErrors(diags) || HasErrors(diags[:1]) {
+		t.Errorf("Expected only the second diagnostic to be an error")
+	}
+
+	if s := diags[0].Error(); s != "items.json:4:5: warning: second" {
+		t.Errorf("Unexpected message: %s", s)
+	}
+
+	if s := (Diagnostic{File: "items.yaml", Line: 3, Message: "third"}).Error(); s != "items.yaml:3: third" {
+		t.Errorf("Unexpected message: %s", s)
+	}
+}
+
+// Tests that problems are reported at the position of their element in a list under a key, ignoring case
+func TestLocateIn(t *testing.T) {
+	data := "{\n  \"Other\": [1, 2],\n  \"items\": [\n    {\"Title\": \"one\"}, {\"Title\": \"two\"}\n  ]\n}"
+
+	diags := LocateIn("items.json", []byte(data), "Items", []Problem{{Index: 1, Severity: SeverityError, Message: "second"}})
+	expected := []Diagnostic{{File: "items.json", Line: 4, Column: 23, Severity: SeverityError, Message: "second"}}
+	if !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics: %+v", diags)
+	}
+
+	diags = LocateIn("items.json", []byte(data), "Missing", []Problem{{Index: 1, Severity: SeverityError, Message: "second"}})
+	expected = []Diagnostic{{File: "items.json", Severity: SeverityError, Message: "second"}}
+	if !reflect.DeepEqual(diags, expected) {
+		t.Errorf("Unexpected diagnostics: %+v", diags)
+	}
+}
@@ -0,0 +1,212 @@
+// Package journal records the changes a run makes to OmniFocus, so that the run can be undone.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// Action is a change made to a task
+type Action string
+
+const (
+	// ActionAdd added the task
+	ActionAdd Action = "add"
+	// ActionComplete completed the task
+	ActionComplete Action = "complete"
+	// ActionDrop dropped the task
+	ActionDrop Action = "drop"
+	// ActionDelete deleted the task
+	ActionDelete Action = "delete"
+	// ActionReopen reopened the task
+	ActionReopen Action = "reopen"
+	// ActionTag added a tag to the task
+	ActionTag Action = "tag"
+	// ActionUntag removed a tag from the task
+	ActionUntag Action = "untag"
+	// ActionMove moved the task to another project
+	ActionMove Action = "move"
+	// ActionUpdate changed the fields of the task
+	ActionUpdate Action = "update"
+)
+
+// idFormat is the layout of run IDs, which sort in the order the runs started
+const idFormat = "20060102T150405.000Z"
+
+// Entry is a single change made to a task
+type Entry struct {
+	// Source is the name of the source the task belongs to
+	Source string `json:"source"`
+	// ExternalID identifies the item of the task at its source, its URL
+	ExternalID string `json:"externalId"`
+	// Action is what was done to the task
+	Action Action `json:"action"`
+	// TaskID is the ID of the task
+	TaskID string `json:"taskId"`
+	// Name is the name of the task
+	Name string `json:"name"`
+	// Previous is the state of the task before the change. It is empty for added tasks
+	Previous *omnifocus.TaskState `json:"previous,omitempty"`
+	// UndoneAt is when the change was undone, if it was
+	UndoneAt *time.Time `json:"undoneAt,omitempty"`
+}
+
+// Journal is the list of changes a run made, in the order they were made
+type Journal struct {
+	dir string
+	// ID identifies the run
+	ID string `json:"id"`
+	// StartedAt is when the run started
+	StartedAt time.Time `json:"startedAt"`
+	// UndoneAt is when the run was undone, if it was. It is only set once every change of the run was undone
+	UndoneAt *time.Time `json:"undoneAt,omitempty"`
+	// Entries are the changes of the run
+	Entries []Entry `json:"entries"`
+}
+
+// MARK: Private helper methods
+// runsDir returns the directory journals are kept in
+func runsDir(dir string) string {
+	return path.Join(dir, "runs")
+}
+
+// MARK: Public methods
+// New returns an empty journal for a run started at the given time, kept in the given directory
+func New(dir string, at time.Time) *Journal {
+	return &Journal{
+		dir:       dir,
+		ID:        at.UTC().Format(idFormat),
+		StartedAt: at,
+		Entries:   []Entry{},
+	}
+}
+
+// Record adds the change to the journal and saves it, so the change can be undone even if the run
+// doesn't finish
+func (j *Journal) Record(e Entry) error {
+	j.Entries = append(j.Entries, e)
+	return j.Save()
+}
+
+// Save stores the journal in its directory. Journals without entries aren't stored.
+func (j *Journal) Save() error {
+	if len(j.Entries) == 0 {
+		return nil
+	}
+
+	bytes, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+
+	err = os.MkdirAll(runsDir(j.dir), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	p := path.Join(runsDir(j.dir), j.ID+".json")
+
+	// Write to a temporary file first so a crash never leaves a half written journal behind
+	err = os.WriteFile(p+".tmp", bytes, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+// List returns the IDs of the stored journals, oldest first
+func List(dir string) ([]string, error) {
+	files, err := os.ReadDir(runsDir(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list journals: %w", err)
+	}
+
+	var ids []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Load returns the journal of the run with the given ID, or of the latest run when the ID is empty
+func Load(dir, id string) (*Journal, error) {
+	if id == "" {
+		ids, err := List(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no runs to undo")
+		}
+
+		id = ids[len(ids)-1]
+	}
+
+	bytes, err := os.ReadFile(path.Join(runsDir(dir), id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run %s does not exist", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load journal: %w", err)
+	}
+
+	j := &Journal{dir: dir}
+	err = json.Unmarshal(bytes, j)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode journal: %w", err)
+	}
+
+	return j, nil
+}
+
+// Undo reverses the entries of the journal that weren't undone yet, newest first, using the given function for
+// each of them. Entries that fail are reported together after trying every other one, and are tried again by
+// the next undo. The journal is marked undone once every entry was undone.
+func (j *Journal) Undo(at time.Time, undo func(Entry) error) error {
+	if j.UndoneAt != nil {
+		return fmt.Errorf("run %s was already undone at %s", j.ID, j.UndoneAt.Format(time.RFC3339))
+	}
+
+	var failed []string
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := j.Entries[i]
+		if e.UndoneAt != nil {
+			continue
+		}
+
+		if err := undo(e); err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %s", e.Action, e.Name, err))
+			continue
+		}
+
+		j.Entries[i].UndoneAt = &at
+	}
+
+	if len(failed) == 0 {
+		j.UndoneAt = &at
+	}
+
+	if err := j.Save(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to undo %d changes, undo the run again to retry them:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+
+	return nil
+}
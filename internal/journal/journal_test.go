@@ -0,0 +1,106 @@
+package journal
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+var started = time.Date(2023, 11, 14, 10, 0, 0, 0, time.UTC)
+
+// MARK: Journal tests
+// Tests that a recorded journal can be loaded by its ID, or as the latest run
+func TestRecordLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	first := New(dir, started)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Runs without changes aren't kept
+	if ids, _ := List(dir); len(ids) != 0 {
+		t.Fatalf("Expected no runs, was: %v", ids)
+	}
+
+	first.Record(Entry{Source: "GitHub", Action: ActionAdd, TaskID: "a1", Name: "[1] One"})
+
+	second := New(dir, started.Add(time.Minute))
+	previous := omnifocus.TaskState{ID: "a2", Name: "[2] Two", Tags: []string{"github"}}
+	second.Record(Entry{Source: "GitHub", Action: ActionComplete, TaskID: "a2", Name: "[2] Two", Previous: &previous})
+
+	ids, err := List(dir)
+	if err != nil || !reflect.DeepEqual(ids, []string{"20231114T100000.000Z", "20231114T100100.000Z"}) {
+		t.Fatalf("Unexpected runs: %v (%v)", ids, err)
+	}
+
+	latest, err := Load(dir, "")
+	if err != nil || latest.ID != second.ID || !reflect.DeepEqual(latest.Entries[0].Previous, &previous) {
+		t.Fatalf("Unexpected journal: %+v (%v)", latest, err)
+	}
+
+	loaded, err := Load(dir, first.ID)
+	if err != nil || len(loaded.Entries) != 1 || loaded.Entries[0].TaskID != "a1" {
+		t.Fatalf("Unexpected journal: %+v (%v)", loaded, err)
+	}
+
+	if _, err := Load(dir, "missing"); err == nil || err.Error() != "run missing does not exist" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := Load(t.TempDir(), ""); err == nil || err.Error() != "no runs to undo" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Tests that undoing reverses the entries newest first, that failed entries are retried by the next undo and
+// that a run is only undone once
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	j := New(dir, started)
+	j.Record(Entry{Action: ActionAdd, TaskID: "a1", Name: "[1] One"})
+	j.Record(Entry{Action: ActionTag, TaskID: "a2", Name: "[2] Two"})
+	j.Record(Entry{Action: ActionComplete, TaskID: "a3", Name: "[3] Three"})
+
+	var undone []string
+	err := j.Undo(started.Add(time.Hour), func(e Entry) error {
+		undone = append(undone, e.TaskID)
+		if e.Action == ActionTag {
+			return errors.New("task not found")
+		}
+		return nil
+	})
+
+	if !reflect.DeepEqual(undone, []string{"a3", "a2", "a1"}) {
+		t.Fatalf("Unexpected order: %v", undone)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "failed to undo 1 changes, undo the run again to retry them:\n  tag [2] Two: task not found") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, _ := Load(dir, j.ID)
+	if loaded.UndoneAt != nil {
+		t.Fatalf("Expected the run not to be undone with a failed change, was undone at: %s", loaded.UndoneAt)
+	}
+
+	undone = nil
+	err = loaded.Undo(started.Add(time.Hour), func(e Entry) error {
+		undone = append(undone, e.TaskID)
+		return nil
+	})
+
+	if err != nil || !reflect.DeepEqual(undone, []string{"a2"}) {
+		t.Fatalf("Expected only the failed change to be retried, was: %v (%v)", undone, err)
+	}
+
+	loaded, _ = Load(dir, j.ID)
+	err = loaded.Undo(time.Now(), func(e Entry) error { return nil })
+	if err == nil || err.Error() != "run 20231114T100000.000Z was already undone at 2023-11-14T11:00:00Z" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package lock
+
+import (
+	"os"
+)
+
+// tryLock returns whether the lock is free, without advisory locks: the process written in the file must no
+// longer exist. This is weaker than the advisory lock of Unix systems, since two processes may both find the
+// lock free at the same time.
+func tryLock(f *os.File) (bool, error) {
+	holder := readHolder(f)
+	if holder.PID == 0 || holder.PID == os.Getpid() {
+		return true, nil
+	}
+
+	if host, _ := os.Hostname(); holder.Host != host {
+		return false, nil
+	}
+
+	_, err := os.FindProcess(holder.PID)
+	return err != nil, nil
+}
+
+// unlock does nothing, since emptying the file releases the lock
+func unlock(f *os.File) error {
+	return nil
+}
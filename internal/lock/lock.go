@@ -0,0 +1,181 @@
+// Package lock keeps two omnisync processes from syncing at the same time, which would add the same tasks twice.
+// The lock is an advisory lock on a file in the state directory, which the operating system releases when its
+// process exits, so a crashed process never leaves it held.
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// FileName is the name of the lock file in the state directory
+const FileName = "omnisync.lock"
+
+// pollInterval is how often a held lock is tried again while waiting for it
+const pollInterval = 250 * time.Millisecond
+
+// sleep pauses between attempts. It is replaced in tests.
+var sleep = time.Sleep
+
+// Holder is the process holding the lock, as written in the lock file
+type Holder struct {
+	PID  int    `json:"pid"`
+	Host string `json:"host"`
+	// Command is the omnisync command the process runs, e.g. `sync`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+// Lock is a held lock
+type Lock struct {
+	file *os.File
+}
+
+// LockedError is returned when another process holds the lock
+type LockedError struct {
+	Path   string
+	Holder Holder
+}
+
+func (e *LockedError) Error() string {
+	h := e.Holder
+	if h.PID == 0 {
+		return fmt.Sprintf("another omnisync is already running, holding %s", e.Path)
+	}
+
+	return fmt.Sprintf("omnisync %s is already running as process %d on %s since %s, holding %s", h.Command, h.PID, h.Host, h.Since.Local().Format("2006-01-02 15:04:05"), e.Path)
+}
+
+// MARK: Private helper methods
+// readHolder returns the holder written in the lock file, which is empty when the lock was released
+func readHolder(f *os.File) Holder {
+	var holder Holder
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return holder
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil || len(data) == 0 {
+		return holder
+	}
+
+	_ = json.Unmarshal(data, &holder)
+	return holder
+}
+
+// describe names the process holding the lock
+func (h Holder) describe() string {
+	if h.PID == 0 {
+		return "another omnisync to finish"
+	}
+
+	return fmt.Sprintf("omnisync %s (process %d) to finish", h.Command, h.PID)
+}
+
+// writeHolder replaces the contents of the lock file with the holder
+func writeHolder(f *os.File, holder Holder) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err = f.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// try takes the lock in the directory for the command, or returns a LockedError when another process holds it
+func try(dir, command string) (*Lock, error) {
+	file := path.Join(dir, FileName)
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the lock %s: %w", file, err)
+	}
+
+	locked, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", file, err)
+	}
+
+	if !locked {
+		holder := readHolder(f)
+		f.Close()
+		return nil, &LockedError{Path: file, Holder: holder}
+	}
+
+	// Released locks are emptied, so a holder left in the file is a process that exited while holding the lock
+	if stale := readHolder(f); stale.PID != 0 {
+		log.Printf("[lock] Taking over the lock of process %d (omnisync %s since %s), which exited without releasing it", stale.PID, stale.Command, stale.Since.Local().Format(time.RFC3339))
+	}
+
+	host, _ := os.Hostname()
+	err = writeHolder(f, Holder{PID: os.Getpid(), Host: host, Command: command, Since: time.Now()})
+	if err != nil {
+		unlock(f)
+		f.Close()
+		return nil, fmt.Errorf("failed to write the lock %s: %w", file, err)
+	}
+
+	return &Lock{file: f}, nil
+}
+
+// MARK: Public methods
+// Acquire takes the lock in the directory for the command, waiting up to the given time for another process to
+// release it. A wait of zero fails straight away with a LockedError naming the process that holds the lock.
+func Acquire(dir, command string, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	deadline := time.Now().Add(wait)
+	logged := false
+	for {
+		l, err := try(dir, command)
+		if err == nil {
+			return l, nil
+		}
+
+		locked, ok := err.(*LockedError)
+		if !ok || !time.Now().Before(deadline) {
+			return nil, err
+		}
+
+		if !logged {
+			log.Printf("[lock] Waiting up to %s for %s", wait, locked.Holder.describe())
+			logged = true
+		}
+
+		sleep(pollInterval)
+	}
+}
+
+// Release empties and releases the lock
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	f := l.file
+	l.file = nil
+
+	truncErr := f.Truncate(0)
+	unlockErr := unlock(f)
+	closeErr := f.Close()
+
+	for _, err := range []error{truncErr, unlockErr, closeErr} {
+		if err != nil {
+			return fmt.Errorf("failed to release the lock: %w", err)
+		}
+	}
+
+	return nil
+}
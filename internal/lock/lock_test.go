@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// MARK: Acquire tests
+// Tests that a held lock can't be taken again and names its holder, and is free again once released
+func TestAcquire(t *testing.T) {
+	dir := path.Join(t.TempDir(), "state")
+
+	l, err := Acquire(dir, "sync", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err = Acquire(dir, "undo", 0)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Expected the lock to be held, was: %v", err)
+	}
+
+	if locked.Holder.PID != os.Getpid() || locked.Holder.Command != "sync" || locked.Path != path.Join(dir, FileName) {
+		t.Errorf("Unexpected holder: %+v", locked)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	l, err = Acquire(dir, "undo", 0)
+	if err != nil {
+		t.Fatalf("Expected the released lock to be free, was: %v", err)
+	}
+	l.Release()
+}
+
+// Tests that a second process waits for the lock until it is released, or until it gives up
+func TestAcquireWait(t *testing.T) {
+	dir := t.TempDir()
+	var waits int
+	sleep = func(d time.Duration) {
+		waits++
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	l, _ := Acquire(dir, "sync", 0)
+	if _, err := Acquire(dir, "daemon", 20*time.Millisecond); err == nil || waits == 0 {
+		t.Fatalf("Expected to give up after waiting, was: %v after %d waits", err, waits)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Release()
+	}()
+
+	second, err := Acquire(dir, "daemon", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected the lock once released, was: %v", err)
+	}
+	second.Release()
+}
+
+// Tests that the lock left by a process that exited without releasing it is taken over
+func TestAcquireStale(t *testing.T) {
+	dir := t.TempDir()
+	stale, _ := json.Marshal(Holder{PID: 999999, Host: "elsewhere", Command: "sync", Since: time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(path.Join(dir, FileName), stale, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Acquire(dir, "sync", 0)
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, was: %v", err)
+	}
+	defer l.Release()
+
+	f, _ := os.Open(path.Join(dir, FileName))
+	defer f.Close()
+	if holder := readHolder(f); holder.PID != os.Getpid() {
+		t.Errorf("Unexpected holder: %+v", holder)
+	}
+}
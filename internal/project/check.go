@@ -0,0 +1,129 @@
+package project
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+)
+
+// MARK: Private helper methods
+// label returns how the project is named in problems
+func (p Project) label() string {
+	switch {
+	case p.Inbox:
+		return "Inbox"
+	case p.OFName != "":
+		return p.OFName
+	case p.NameTemplate != "":
+		return p.NameTemplate
+	default:
+		return p.URL
+	}
+}
+
+// checkURL returns an error if the project's URL looks like a URL but isn't a valid web URL
+func (p Project) checkURL() error {
+	if p.urlMatch() == MatchRegex || !strings.Contains(p.URL, "://") {
+		return nil
+	}
+
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL `%s`: %w", p.URL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL `%s`: unknown scheme `%s`, expected `http` or `https`", p.URL, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL `%s`: no host", p.URL)
+	}
+
+	return nil
+}
+
+// covers returns whether the project's URL matches every URL the other project's URL matches
+func (p Project) covers(other Project) bool {
+	m, otherMatch := p.urlMatch(), other.urlMatch()
+	switch m {
+	case MatchContains:
+		return otherMatch != MatchRegex && strings.Contains(other.URL, p.URL)
+	case MatchPrefix:
+		return (otherMatch == MatchPrefix || otherMatch == MatchExact) && strings.HasPrefix(other.URL, p.URL)
+	default:
+		return otherMatch == m && other.URL == p.URL
+	}
+}
+
+// hasRules returns whether every rule of the project is also a rule of the other project, so the
+// project's rules hold whenever the other project's do
+func (p Project) hasRules(other Project) bool {
+	for _, rule := range p.Rules {
+		found := false
+		for _, o := range other.Rules {
+			if o.Field == rule.Field && o.ruleMatch() == rule.ruleMatch() && o.Value == rule.Value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shadows returns whether the project, at index i, wins every item the other project, at index j, matches
+func (p Project) shadows(i int, other Project, j int) bool {
+	// A name template can fail to render for an item, letting the other project have it
+	if p.NameTemplate != "" || !p.covers(other) || !p.hasRules(other) {
+		return false
+	}
+
+	// Ties go to the project listed first
+	return p.specificity() > other.specificity() || (p.specificity() == other.specificity() && i < j)
+}
+
+// MARK: Public methods
+// Check returns every problem of the projects, by their index: the ones that stop them from loading,
+// URLs that aren't valid web URLs, names used by several projects and projects that never win an item
+// because another project wins every item they match.
+func Check(projects []Project) []diag.Problem {
+	var problems []diag.Problem
+	add := func(i int, severity diag.Severity, format string, args ...interface{}) {
+		problems = append(problems, diag.Problem{Index: i, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := map[string]int{}
+	for i, p := range projects {
+		if err := p.validate(); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		if err := p.checkURL(); err != nil {
+			add(i, diag.SeverityError, "project %s has an %s", p.label(), err)
+		}
+
+		if p.OFName != "" {
+			if first, ok := names[p.OFName]; ok {
+				add(i, diag.SeverityWarning, "project %s is also configured as project %d, so the items of both go to the same project", p.OFName, first+1)
+			} else {
+				names[p.OFName] = i
+			}
+		}
+
+		for j, other := range projects {
+			if j != i && other.shadows(j, p, i) {
+				add(i, diag.SeverityError, "project %s is unreachable: project %d (%s) matches every item it does and wins", p.label(), j+1, other.label())
+				break
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,63 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+)
+
+// MARK: Check tests
+// Tests that invalid URLs, duplicate names and unreachable projects are reported by their index
+func TestCheck(t *testing.T) {
+	projects := []Project{
+		{URL: "https://github.com/org", OFName: "Org"},
+		{URL: "httsp://app.shortcut.com", OFName: "Shortcut"},
+		{URL: "https://github.com/org/repo", OFName: "Org"},
+		// Shadowed by the first project, which is listed first and equally specific
+		{URL: "https://github.com/org", OFName: "Copy"},
+		// A prefix matches a subset of what contains does, so it ties with the previous project and loses
+		{URL: "https://github.com/org/app", OFName: "Contains", Rules: []Rule{{Field: "state", Value: "open"}}},
+		{URL: "https://github.com/org/app", Match: MatchPrefix, OFName: "Prefix", Rules: []Rule{{Field: "state", Value: "open"}}},
+		// More rules win over the shorter URL, so it is reachable
+		{URL: "github.com", OFName: "Bugs", Rules: []Rule{{Field: "labels.name", Value: "bug"}}},
+		// Templates can fail to render, so they never shadow
+		{URL: "https://gitlab.com", NameTemplate: "GL: {{.project}}"},
+		{URL: "https://gitlab.com", OFName: "GitLab"},
+		{URL: "https://linear.app", OFName: "Linear", Inbox: true},
+	}
+
+	expected := []diag.Problem{
+		{Index: 1, Severity: diag.SeverityError, Message: "project Shortcut has an invalid URL `httsp://app.shortcut.com`: unknown scheme `httsp`, expected `http` or `https`"},
+		{Index: 2, Severity: diag.SeverityWarning, Message: "project Org is also configured as project 1, so the items of both go to the same project"},
+		{Index: 3, Severity: diag.SeverityError, Message: "project Copy is unreachable: project 1 (Org) matches every item it does and wins"},
+		{Index: 5, Severity: diag.SeverityError, Message: "project Prefix is unreachable: project 5 (Contains) matches every item it does and wins"},
+		{Index: 9, Severity: diag.SeverityError, Message: "invalid project https://linear.app: an Inbox project can't set `OFName`, `NameTemplate` or `Create`"},
+	}
+
+	problems := Check(projects)
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems:\n%+v\nexpected:\n%+v", problems, expected)
+	}
+}
+
+// Tests which URLs a project covers
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		p, other Project
+		expected bool
+	}{
+		{Project{URL: "github.com"}, Project{URL: "https://github.com/org"}, true},
+		{Project{URL: "github.com/org"}, Project{URL: "github.com"}, false},
+		{Project{URL: "https://github.com", Match: MatchPrefix}, Project{URL: "https://github.com/org", Match: MatchExact}, true},
+		{Project{URL: "https://github.com", Match: MatchPrefix}, Project{URL: "https://github.com/org"}, false},
+		{Project{URL: "github", Match: MatchRegex}, Project{URL: "github", Match: MatchRegex}, true},
+		{Project{URL: "github"}, Project{URL: "github", Match: MatchRegex}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.p.covers(test.other); got != test.expected {
+			t.Errorf("Expected %+v to cover %+v: %t, was: %t", test.p, test.other, test.expected, got)
+		}
+	}
+}
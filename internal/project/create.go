@@ -0,0 +1,68 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProjectType is the kind of OmniFocus project
+type ProjectType string
+
+const (
+	// Parallel projects have actions that can be done in any order
+	Parallel ProjectType = "parallel"
+	// Sequential projects have actions that are done one after the other
+	Sequential ProjectType = "sequential"
+	// SingleAction projects are lists of unrelated actions
+	SingleAction ProjectType = "single-action"
+)
+
+// Creation describes how a missing OmniFocus project is created
+type Creation struct {
+	// The folder the project is created in, with nested folders separated by slashes, e.g. `Work / Repos`.
+	// Missing folders are created too. Leave empty to create the project at the top level
+	Folder string `json:",omitempty"`
+	// The type of the project: `parallel`, `sequential` or `single-action`. Defaults to `parallel`
+	Type ProjectType `json:",omitempty"`
+	// The number of days between reviews of the project. Leave empty to use the OmniFocus default
+	ReviewIntervalDays int `json:",omitempty"`
+	// The tags added to the project
+	Tags []string `json:",omitempty"`
+}
+
+// FolderPath returns the names of the nested folders the project is created in, outermost first
+func (c Creation) FolderPath() []string {
+	var path []string
+	for _, name := range strings.Split(c.Folder, "/") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			path = append(path, name)
+		}
+	}
+
+	return path
+}
+
+// GetType returns the type of the project that is created
+func (c Creation) GetType() ProjectType {
+	if c.Type == "" {
+		return Parallel
+	}
+
+	return c.Type
+}
+
+// validate returns an error if the project can't be created as described
+func (c Creation) validate() error {
+	switch c.GetType() {
+	case Parallel, Sequential, SingleAction:
+	default:
+		return fmt.Errorf("unknown project type `%s`", c.Type)
+	}
+
+	if c.ReviewIntervalDays < 0 {
+		return fmt.Errorf("negative review interval")
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+// MARK: Creation tests
+func TestFolderPath(t *testing.T) {
+	path := Creation{Folder: " Work / Repos/ "}.FolderPath()
+	if !reflect.DeepEqual(path, []string{"Work", "Repos"}) {
+		t.Fatalf("Unexpected folder path: %v", path)
+	}
+
+	if path := (Creation{}).FolderPath(); len(path) != 0 {
+		t.Fatalf("Expected no folders, was: %v", path)
+	}
+}
+
+func TestCreationValidate(t *testing.T) {
+	if (Creation{}).GetType() != Parallel {
+		t.Fatal("Expected parallel projects by default")
+	}
+
+	err := Project{OFName: "Broken", Create: &Creation{Type: "kanban"}}.validate()
+	if err == nil || err.Error() != "invalid project Broken: unknown project type `kanban`" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package project
+
+import (
+	"fmt"
+)
+
+// Fallback says where the items that don't match any project go
+type Fallback struct {
+	// The name of the OF project unmatched items are added to
+	Project string `json:"Project"`
+	// Add unmatched items to the OmniFocus Inbox instead of a project
+	Inbox bool `json:"Inbox"`
+	// Leave unmatched items out of OmniFocus and log a warning listing them
+	Skip bool `json:"Skip"`
+}
+
+// IsSet returns whether the fallback names a destination or skips items
+func (f Fallback) IsSet() bool {
+	return f.Project != "" || f.Inbox || f.Skip
+}
+
+// Validate returns an error if the fallback names more than one destination
+func (f Fallback) Validate() error {
+	n := 0
+	for _, set := range []bool{f.Project != "", f.Inbox, f.Skip} {
+		if set {
+			n++
+		}
+	}
+
+	if n > 1 {
+		return fmt.Errorf("a fallback can only set one of `Project`, `Inbox` or `Skip`")
+	}
+
+	return nil
+}
+
+// String prints out a description of where the fallback sends items
+func (f Fallback) String() string {
+	switch {
+	case f.Inbox:
+		return "the Inbox"
+	case f.Project != "":
+		return fmt.Sprintf("project %s", f.Project)
+	default:
+		return "nowhere"
+	}
+}
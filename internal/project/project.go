@@ -1,12 +1,12 @@
 package project
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path"
-	"strings"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
 )
 
 // Project represents the connection between a source of items and an OmniFocus project.
@@ -15,10 +15,25 @@ type Project struct {
 	URL string
 	// The name of the OF project for all the issues
 	OFName string
+	// A Go template over the fields of an issue that gives the name of its OF project instead of
+	// OFName, e.g. `GH: {{.repository.name}}`
+	NameTemplate string `json:",omitempty"`
+	// How the URL is compared to the URL of an issue: `contains`, `prefix`, `exact` or `regex`. Defaults to `contains`
+	Match MatchType `json:",omitempty"`
+	// Predicates on the fields of an issue that must all hold for it to be added to the project
+	Rules []Rule `json:",omitempty"`
+	// Creates the OF project if it doesn't exist yet. Leave empty to require the project to exist
+	Create *Creation `json:",omitempty"`
+	// Adds the issues to the OF Inbox instead of a project, leaving OFName empty
+	Inbox bool `json:",omitempty"`
 }
 
 // String prints out a formatted description of the project
-func (p *Project) String() string {
+func (p Project) String() string {
+	if p.Inbox {
+		return fmt.Sprintf("Inbox: %s", p.URL)
+	}
+
 	return fmt.Sprintf("%s: %s", p.OFName, p.URL)
 }
 
@@ -36,14 +51,31 @@ func LoadProjects(Path string) ([]Project, error) {
 
 	var projects []Project
 
-	err = json.Unmarshal(bytes, &projects)
+	err = diag.Decode(projectPath, bytes, &projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode projects: %w", err)
+	}
+
+	err = Validate(projects)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode projects")
+		return nil, err
 	}
 
 	return projects, nil
 }
 
+// Validate returns the first error of the projects that stops them from routing items
+func Validate(projects []Project) error {
+	for _, project := range projects {
+		err := project.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // GetProject returns the project with the name `key` in OmniFocus
 func GetProject(key string, projects []Project) (Project, error) {
 	for i := range projects {
@@ -55,12 +87,8 @@ func GetProject(key string, projects []Project) (Project, error) {
 	return Project{}, fmt.Errorf("Project `%s` does not exist", key)
 }
 
+// ProjectFor returns the most specific project matching the URL, ignoring projects with field rules
 func ProjectFor(url string, projects []Project) (Project, error) {
-	for _, project := range projects {
-		if strings.Contains(url, project.URL) {
-			return project, nil
-		}
-	}
-
-	return Project{}, fmt.Errorf("URL %s does not match any projects", url)
+	project, _, err := Route(url, nil, projects)
+	return project, err
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -61,11 +62,11 @@ func TestLoadProjectsSuccess(t *testing.T) {
 		t.Fatalf("Expected size 2, was: %d\n", len(projects))
 	}
 
-	if projects[0] != project1 {
+	if !reflect.DeepEqual(projects[0], project1) {
 		t.Fatal("Expected first project")
 	}
 
-	if projects[1] != project2 {
+	if !reflect.DeepEqual(projects[1], project2) {
 		t.Fatal("Expected second project")
 	}
 }
@@ -83,7 +84,7 @@ func TestLoadProjectsNoFile(t *testing.T) {
 func TestLoadProjectsInvalidData(t *testing.T) {
 	_, err := LoadProjects("../../testData/invalid")
 
-	if err.Error() != "failed to decode projects" {
+	if err.Error() != "failed to decode projects: ../../testData/invalid/projects.json:5:7: invalid character '\"' after object key:value pair" {
 		t.Fatalf("Unexpected err: %v", err)
 	}
 }
@@ -101,7 +102,7 @@ func TestGetProjectSuccess(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	if project != project1 {
+	if !reflect.DeepEqual(project, project1) {
 		t.Fatalf("Expected project1, got: %s", project)
 	}
 
@@ -110,7 +111,7 @@ func TestGetProjectSuccess(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	if project != project2 {
+	if !reflect.DeepEqual(project, project2) {
 		t.Fatalf("Expected project2, got: %s", project)
 	}
 }
@@ -128,7 +129,7 @@ func TestGetProjectDoesNotExist(t *testing.T) {
 		t.Fatal("Error not thrown")
 	}
 
-	if project != (Project{}) {
+	if !reflect.DeepEqual(project, Project{}) {
 		t.Fatal("Project is non empty")
 	}
 }
@@ -140,7 +141,7 @@ func TestProjectForSuccess(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Unexpected error: %s", err)
 	}
-	if project != project1 {
+	if !reflect.DeepEqual(project, project1) {
 		t.Fatalf("Unexpected project: %s", project)
 	}
 
@@ -148,7 +149,7 @@ func TestProjectForSuccess(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Unexpected error: %s", err)
 	}
-	if project != project2 {
+	if !reflect.DeepEqual(project, project2) {
 		t.Fatalf("Unexpected project: %s", project)
 	}
 }
@@ -159,7 +160,7 @@ func TestProjectForNoMatch(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 
-	if project != (Project{}) {
+	if !reflect.DeepEqual(project, Project{}) {
 		t.Fatal("Expected empty project")
 	}
 }
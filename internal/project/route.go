@@ -0,0 +1,374 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MatchType says how a pattern is compared to a value
+type MatchType string
+
+const (
+	// MatchContains matches values containing the pattern
+	MatchContains MatchType = "contains"
+	// MatchPrefix matches values starting with the pattern
+	MatchPrefix MatchType = "prefix"
+	// MatchExact matches values equal to the pattern
+	MatchExact MatchType = "exact"
+	// MatchRegex matches values matching the pattern as a regular expression
+	MatchRegex MatchType = "regex"
+)
+
+// Rule is a predicate on a field of an item
+type Rule struct {
+	// The field to check. Nested fields are separated by dots and arrays are searched, so `labels.name`
+	// checks the name of every label of a GitHub issue
+	Field string
+	// How the value of the field is compared to the pattern. Defaults to `exact`
+	Match MatchType
+	// The value or regular expression the field is compared to
+	Value string
+}
+
+// Candidate is the outcome of checking one project against an item
+type Candidate struct {
+	// The project that was checked
+	Project Project
+	// Whether every condition of the project holds for the item
+	Matched bool
+	// Why the project did or didn't match
+	Reason string
+	// How specific the project is, higher is more specific
+	Specificity int
+}
+
+// Trace explains how an item was routed to a project
+type Trace []Candidate
+
+// String prints out one line per checked project, marking the project that won
+func (t Trace) String() string {
+	best := t.best()
+
+	var b strings.Builder
+	for i, c := range t {
+		marker := " "
+		if i == best {
+			marker = "*"
+		}
+
+		name := c.Project.OFName
+		if c.Project.Inbox {
+			name = "Inbox"
+		} else if name == "" {
+			name = c.Project.NameTemplate
+		}
+
+		fmt.Fprintf(&b, "%s %d. %s: %s\n", marker, i+1, name, c.Reason)
+	}
+
+	return b.String()
+}
+
+// best returns the index of the most specific matching candidate, or -1 when none matched. Ties go to
+// the project listed first.
+func (t Trace) best() int {
+	best := -1
+	for i, c := range t {
+		if c.Matched && (best == -1 || c.Specificity > t[best].Specificity) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// regexps caches compiled patterns, so each one is only compiled once per run
+var regexps = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+// templates caches parsed name templates, so each one is only parsed once per run
+var templates = struct {
+	sync.Mutex
+	m map[string]*template.Template
+}{m: map[string]*template.Template{}}
+
+// MARK: Private helper methods
+// parseTemplate returns the parsed name template
+func parseTemplate(text string) (*template.Template, error) {
+	templates.Lock()
+	defer templates.Unlock()
+
+	if t, ok := templates.m[text]; ok {
+		return t, nil
+	}
+
+	// Missing fields are an error rather than `<no value>`, so issues without them don't end up in an
+	// oddly named project
+	t, err := template.New("project").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	templates.m[text] = t
+	return t, nil
+}
+
+// name returns the name of the OF project for an issue with the given fields
+func (p Project) name(fields map[string]interface{}) (string, error) {
+	if p.NameTemplate == "" {
+		return p.OFName, nil
+	}
+
+	t, err := parseTemplate(p.NameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid name template `%s`: %w", p.NameTemplate, err)
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+
+	name := strings.TrimSpace(b.String())
+	if name == "" {
+		return "", fmt.Errorf("name template `%s` rendered an empty name", p.NameTemplate)
+	}
+
+	return name, nil
+}
+
+// compile returns the compiled regular expression for the pattern
+func compile(pattern string) (*regexp.Regexp, error) {
+	regexps.Lock()
+	defer regexps.Unlock()
+
+	if re, ok := regexps.m[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexps.m[pattern] = re
+	return re, nil
+}
+
+// matches returns whether the value matches the pattern
+func (m MatchType) matches(pattern, value string) (bool, error) {
+	switch m {
+	case MatchContains:
+		return strings.Contains(value, pattern), nil
+	case MatchPrefix:
+		return strings.HasPrefix(value, pattern), nil
+	case MatchExact:
+		return value == pattern, nil
+	case MatchRegex:
+		re, err := compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid regex `%s`: %w", pattern, err)
+		}
+
+		return re.MatchString(value), nil
+	default:
+		return false, fmt.Errorf("unknown match type `%s`", m)
+	}
+}
+
+// rank returns how specific the match type is. Exact matches beat every other type.
+func (m MatchType) rank() int {
+	if m == MatchExact {
+		return 2
+	}
+
+	return 1
+}
+
+// urlMatch returns how the project's URL is compared to the URL of an item
+func (p Project) urlMatch() MatchType {
+	if p.Match == "" {
+		return MatchContains
+	}
+
+	return p.Match
+}
+
+// ruleMatch returns how the rule's value is compared to the field
+func (r Rule) ruleMatch() MatchType {
+	if r.Match == "" {
+		return MatchExact
+	}
+
+	return r.Match
+}
+
+// fieldValues returns the values of the dotted field path in the fields, descending into arrays
+func fieldValues(fields interface{}, path string) []string {
+	if path == "" {
+		switch v := fields.(type) {
+		case nil:
+			return nil
+		case []interface{}:
+			var values []string
+			for _, e := range v {
+				values = append(values, fieldValues(e, "")...)
+			}
+			return values
+		case float64:
+			return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+		default:
+			return []string{fmt.Sprint(v)}
+		}
+	}
+
+	key, rest, _ := strings.Cut(path, ".")
+	switch v := fields.(type) {
+	case map[string]interface{}:
+		return fieldValues(v[key], rest)
+	case []interface{}:
+		var values []string
+		for _, e := range v {
+			values = append(values, fieldValues(e, path)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// specificity returns how specific the project is, higher is more specific
+func (p Project) specificity() int {
+	// Field rules make a project more specific than any URL, then exact URLs beat other match
+	// types and longer patterns beat shorter ones, so `repo-docs` wins over `repo`
+	return len(p.Rules)*100000 + p.urlMatch().rank()*10000 + len(p.URL)
+}
+
+// check returns whether the project matches the item with the given URL and fields
+func (p Project) check(url string, fields map[string]interface{}) Candidate {
+	c := Candidate{
+		Project:     p,
+		Specificity: p.specificity(),
+	}
+
+	ok, err := p.urlMatch().matches(p.URL, url)
+	if err != nil {
+		c.Reason = err.Error()
+		return c
+	} else if !ok {
+		c.Reason = fmt.Sprintf("URL does not %s `%s`", verb(p.urlMatch()), p.URL)
+		return c
+	}
+
+	for _, rule := range p.Rules {
+		values := fieldValues(fields, rule.Field)
+
+		matched := false
+		for _, value := range values {
+			ok, err := rule.ruleMatch().matches(rule.Value, value)
+			if err != nil {
+				c.Reason = err.Error()
+				return c
+			}
+
+			if ok {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			c.Reason = fmt.Sprintf("`%s` %v does not %s `%s`", rule.Field, values, verb(rule.ruleMatch()), rule.Value)
+			return c
+		}
+	}
+
+	name, err := p.name(fields)
+	if err != nil {
+		c.Reason = err.Error()
+		return c
+	}
+
+	c.Project.OFName = name
+	c.Project.NameTemplate = ""
+	c.Matched = true
+	c.Reason = fmt.Sprintf("matched (specificity %d)", c.Specificity)
+	return c
+}
+
+// verb describes the match type in a sentence
+func verb(m MatchType) string {
+	switch m {
+	case MatchPrefix:
+		return "start with"
+	case MatchExact:
+		return "equal"
+	case MatchRegex:
+		return "match"
+	default:
+		return "contain"
+	}
+}
+
+// validate returns an error if the project's patterns can never be evaluated or it can't be created
+func (p Project) validate() error {
+	if _, err := p.urlMatch().matches(p.URL, ""); err != nil {
+		return fmt.Errorf("invalid project %s: %w", p.OFName, err)
+	}
+
+	if p.Inbox {
+		if p.OFName != "" || p.NameTemplate != "" || p.Create != nil {
+			return fmt.Errorf("invalid project %s: an Inbox project can't set `OFName`, `NameTemplate` or `Create`", p.URL)
+		}
+	} else if p.NameTemplate != "" {
+		if _, err := parseTemplate(p.NameTemplate); err != nil {
+			return fmt.Errorf("invalid project %s: invalid name template: %w", p.NameTemplate, err)
+		}
+	} else if p.OFName == "" {
+		return fmt.Errorf("invalid project %s: either `OFName` or `NameTemplate` is required", p.URL)
+	}
+
+	if p.Create != nil {
+		if err := p.Create.validate(); err != nil {
+			return fmt.Errorf("invalid project %s: %w", p.OFName, err)
+		}
+	}
+
+	for _, rule := range p.Rules {
+		if rule.Field == "" {
+			return fmt.Errorf("invalid project %s: rule without a field", p.OFName)
+		}
+
+		if _, err := rule.ruleMatch().matches(rule.Value, ""); err != nil {
+			return fmt.Errorf("invalid project %s: %w", p.OFName, err)
+		}
+	}
+
+	return nil
+}
+
+// MARK: Public methods
+// Route returns the most specific project matching the item with the given URL and fields, along with a
+// trace of how every project was checked. The OFName of the returned project is rendered from its name
+// template, if it has one, and Inbox is set when the item goes to the Inbox. Projects are checked in order, and when several are equally
+// specific the first one wins.
+func Route(url string, fields map[string]interface{}, projects []Project) (Project, Trace, error) {
+	trace := make(Trace, 0, len(projects))
+	for _, project := range projects {
+		trace = append(trace, project.check(url, fields))
+	}
+
+	best := trace.best()
+	if best == -1 {
+		return Project{}, trace, fmt.Errorf("URL %s does not match any projects", url)
+	}
+
+	return trace[best].Project, trace, nil
+}
@@ -0,0 +1,144 @@
+package project
+
+import (
+	"strings"
+	"testing"
+)
+
+// MARK: SETUP
+// issue is an example GitHub issue used for routing tests
+var issue = map[string]interface{}{
+	"number":    12.0,
+	"milestone": map[string]interface{}{"title": "v2"},
+	"labels": []interface{}{
+		map[string]interface{}{"name": "bug"},
+		map[string]interface{}{"name": "docs"},
+	},
+}
+
+// MARK: Route tests
+// Tests that the longest of two overlapping URLs wins
+func TestRouteOverlappingURLs(t *testing.T) {
+	routed := []Project{
+		{URL: "https://api.github.com/repos/owner/repo", OFName: "Repo"},
+		{URL: "https://api.github.com/repos/owner/repo-docs", OFName: "Docs"},
+	}
+
+	project, _, err := Route("https://api.github.com/repos/owner/repo-docs/issues/1", nil, routed)
+	if err != nil || project.OFName != "Docs" {
+		t.Fatalf("Unexpected project: %s (%v)", project.OFName, err)
+	}
+
+	project, _, err = Route("https://api.github.com/repos/owner/repo/issues/1", nil, routed)
+	if err != nil || project.OFName != "Repo" {
+		t.Fatalf("Unexpected project: %s (%v)", project.OFName, err)
+	}
+}
+
+// Tests the different match types of a project URL
+func TestRouteMatchTypes(t *testing.T) {
+	routed := []Project{
+		{URL: "https://api.github.com/repos/owner/", Match: MatchPrefix, OFName: "Prefix"},
+		{URL: `^https://api\.github\.com/repos/owner/[a-z]+/issues/\d+$`, Match: MatchRegex, OFName: "Regex"},
+		{URL: "https://api.github.com/repos/owner/exact/issues/1", Match: MatchExact, OFName: "Exact"},
+	}
+
+	cases := map[string]string{
+		"https://api.github.com/repos/owner/exact/issues/1": "Exact",
+		"https://api.github.com/repos/owner/a/issues/1":     "Regex",
+		"https://api.github.com/repos/owner/a/pulls/1":      "Prefix",
+	}
+
+	for url, expected := range cases {
+		project, _, err := Route(url, nil, routed)
+		if err != nil || project.OFName != expected {
+			t.Fatalf("Expected %s for %s, was: %s (%v)", expected, url, project.OFName, err)
+		}
+	}
+}
+
+// Tests that field rules are checked and beat plain URL matches
+func TestRouteRules(t *testing.T) {
+	routed := []Project{
+		{URL: "github.com", OFName: "GitHub"},
+		{URL: "github.com", OFName: "Bugs", Rules: []Rule{{Field: "labels.name", Value: "bug"}}},
+		{URL: "github.com", OFName: "Release", Rules: []Rule{{Field: "labels.name", Value: "bug"}, {Field: "milestone.title", Match: MatchRegex, Value: "^v3"}}},
+	}
+
+	project, trace, err := Route("https://github.com/owner/repo/issues/12", issue, routed)
+	if err != nil || project.OFName != "Bugs" {
+		t.Fatalf("Unexpected project: %s (%v)", project.OFName, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "* 2. Bugs: matched") {
+		t.Fatalf("Unexpected trace:\n%s", trace)
+	}
+
+	if lines[2] != "  3. Release: `milestone.title` [v2] does not match `^v3`" {
+		t.Fatalf("Unexpected reason: %s", lines[2])
+	}
+}
+
+// Tests that invalid patterns are reported when the projects are loaded
+func TestValidate(t *testing.T) {
+	err := Project{OFName: "Broken", URL: "(", Match: MatchRegex}.validate()
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid project Broken: invalid regex `(`") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = Project{OFName: "Unknown", Match: "glob"}.validate()
+	if err == nil || err.Error() != "invalid project Unknown: unknown match type `glob`" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Tests that a name template derives the project name from the fields of the item
+func TestRouteNameTemplate(t *testing.T) {
+	routed := []Project{
+		{URL: "github.com", NameTemplate: "GH: {{.repository.name}}", Create: &Creation{Folder: "Work / Repos"}},
+	}
+
+	fields := map[string]interface{}{"repository": map[string]interface{}{"name": "omnifocus-sync"}}
+	project, _, err := Route("https://github.com/owner/omnifocus-sync/issues/1", fields, routed)
+	if err != nil || project.OFName != "GH: omnifocus-sync" || project.Create == nil {
+		t.Fatalf("Unexpected project: %v (%v)", project, err)
+	}
+
+	// Items without the field don't match rather than ending up in a project named `<no value>`
+	_, trace, err := Route("https://github.com/owner/omnifocus-sync/issues/1", issue, routed)
+	if err == nil || !strings.Contains(trace.String(), "GH: {{.repository.name}}: failed to render name template") {
+		t.Fatalf("Unexpected trace (%v):\n%s", err, trace)
+	}
+
+	err = Project{NameTemplate: "{{.repository"}.validate()
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid project {{.repository: invalid name template") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Tests that a project can send its items to the Inbox instead of a named project
+func TestRouteInbox(t *testing.T) {
+	routed := []Project{
+		{URL: "github.com", OFName: "GitHub"},
+		{URL: "github.com/owner/triage", Inbox: true},
+	}
+
+	project, trace, err := Route("https://github.com/owner/triage/issues/1", nil, routed)
+	if err != nil || !project.Inbox || project.OFName != "" {
+		t.Fatalf("Unexpected project: %v (%v)", project, err)
+	}
+
+	if !strings.Contains(trace.String(), "* 2. Inbox: matched") {
+		t.Fatalf("Unexpected trace:\n%s", trace)
+	}
+
+	if err := (Project{URL: "github.com", Inbox: true}).validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = Project{URL: "github.com", OFName: "GitHub", Inbox: true}.validate()
+	if err == nil || err.Error() != "invalid project github.com: an Inbox project can't set `OFName`, `NameTemplate` or `Create`" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
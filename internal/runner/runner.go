@@ -0,0 +1,1021 @@
+// Package runner brings the tasks of each source in OmniFocus into line with the items the source returns,
+// recording every change in the journal and the audit log of the run.
+package runner
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/audit"
+	"github.com/trevorpiltch/omnifocus-sync/internal/config"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+	"github.com/trevorpiltch/omnifocus-sync/internal/journal"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
+)
+
+// InboxProject is the project name that selects the Inbox in `Options.Projects`
+const InboxProject = "Inbox"
+
+// OutcomePlanned is the outcome of a change a dry run would make
+const OutcomePlanned audit.Outcome = "planned"
+
+// ActionWriteback is the change that closes an item upstream because its task was closed in OmniFocus
+const ActionWriteback = "writeback"
+
+// Options configures how the sources are synced
+type Options struct {
+	// Force completes tasks even when a source exceeds its safety limits
+	Force bool
+	// Explain logs which project rule matched each item
+	Explain bool
+	// DryRun plans the changes of each source without making them
+	DryRun bool
+	// Projects limits the sync to the items routed to these OmniFocus projects, `Inbox` standing for the Inbox.
+	// Empty syncs every item
+	Projects []string
+	// Limits are the safety limits of sources that don't set their own
+	Limits delta.Limits
+}
+
+// Change is a change made to a task, or one a dry run would make
+type Change struct {
+	Source     string        `json:"source"`
+	Action     string        `json:"action"`
+	Name       string        `json:"name"`
+	ExternalID string        `json:"externalId,omitempty"`
+	TaskID     string        `json:"taskId,omitempty"`
+	Outcome    audit.Outcome `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Result is the outcome of syncing a source
+type Result struct {
+	Source  string   `json:"source"`
+	Fetched int      `json:"fetched"`
+	Changes []Change `json:"changes"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Runner syncs sources with OmniFocus. The journal and audit log are left untouched by dry runs.
+type Runner struct {
+	Projects []project.Project
+	Settings config.Settings
+	// Fetch configures how items are fetched from the sources
+	Fetch source.Options
+	// State holds what was last synced for every item
+	State *state.Store
+	// Journal records every change of the run so it can be undone
+	Journal *journal.Journal
+	// Audit logs what the run fetched and every change it made
+	Audit   *audit.Log
+	Options Options
+
+	// ensured holds the projects that were already checked for auto-creation this run
+	ensured map[string]bool
+}
+
+// pass holds the state of syncing a single source
+type pass struct {
+	*Runner
+	src    source.Source
+	result *Result
+
+	// records are the raw items a targeted sync is limited to, instead of fetching every item of the source
+	records []map[string]interface{}
+	// targets holds the notes and task IDs of the items of a targeted sync. Nil syncs every task of the source
+	targets map[string]bool
+	// held holds the records of the items whose closed task wasn't written back, by note, nil for items without
+	// one. They are kept as they were, so the task still counts as closed after the item was last synced
+	held map[string]*state.Record
+}
+
+// MARK: Private helper methods
+// ensureProject creates the project if it is configured to be created and is missing
+func (r *Runner) ensureProject(p project.Project) error {
+	if r.ensured == nil {
+		r.ensured = map[string]bool{}
+	}
+
+	if r.ensured[p.OFName] || r.Options.DryRun {
+		return nil
+	}
+
+	err := omnifocus.EnsureProject(p)
+	if err != nil {
+		return err
+	}
+
+	r.ensured[p.OFName] = true
+	return nil
+}
+
+// selected returns whether the project or the Inbox is one the sync is limited to
+func (r *Runner) selected(projectName string, inbox bool) bool {
+	if len(r.Options.Projects) == 0 {
+		return true
+	}
+
+	if inbox {
+		projectName = InboxProject
+	}
+
+	for _, p := range r.Options.Projects {
+		if p == projectName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// targeted returns whether the task belongs to one of the items a targeted sync is limited to, by the URL
+// on the first line of its note or by the task it was last synced with
+func (p *pass) targeted(task omnifocus.Item) bool {
+	if p.targets == nil {
+		return true
+	}
+
+	note, _, _ := strings.Cut(task.Note, "\n")
+	return p.targets[strings.TrimSpace(note)] || p.targets[task.ID]
+}
+
+// owns returns whether the task was synced from the source. The tasks of a tagged source link to it on the first
+// line of their note, see `source.Owns`, while the tasks of a source without tags are the ones with a record
+func (p *pass) owns(task omnifocus.Item) bool {
+	if len(p.src.Tags) > 0 {
+		return p.src.Owns(task.Note)
+	}
+
+	note, _, _ := strings.Cut(task.Note, "\n")
+	_, ok := p.State.Get(p.src.Name, strings.TrimSpace(note))
+	return ok
+}
+
+// owned returns the operations without the ones that would complete or remove a task the source doesn't own
+func (p *pass) owned(ops []delta.Operation) []delta.Operation {
+	kept := make([]delta.Operation, 0, len(ops))
+	for _, op := range ops {
+		if op.Type == delta.Remove || op.Type == delta.Complete {
+			task := op.Item.(*omnifocus.Item)
+			if !p.owns(*task) {
+				log.Printf("[runner] Leaving %s alone, it wasn't synced from %s", task.Name, p.src.Name)
+				continue
+			}
+		}
+
+		kept = append(kept, op)
+	}
+
+	return kept
+}
+
+// hold keeps the record of the item as it is when the state is updated after the sync
+func (p *pass) hold(note string) {
+	if p.held == nil {
+		p.held = map[string]*state.Record{}
+	}
+
+	if _, ok := p.held[note]; ok {
+		return
+	}
+
+	if record, ok := p.State.Get(p.src.Name, note); ok {
+		p.held[note] = &record
+	} else {
+		p.held[note] = nil
+	}
+}
+
+// warn logs a failure to keep the journal or audit log of the run
+func warn(what string, err error) {
+	if err != nil {
+		log.Printf("[runner] Failed to %s: %s", what, err)
+	}
+}
+
+// planned reports whether the change is only planned, adding it to the result as such in a dry run
+func (p *pass) planned(action, externalID string, task omnifocus.Item) bool {
+	if !p.Options.DryRun {
+		return false
+	}
+
+	log.Printf("[runner] Would %s %s", action, task.Name)
+	p.result.Changes = append(p.result.Changes, Change{
+		Source:     p.src.Name,
+		Action:     action,
+		Name:       task.Name,
+		ExternalID: externalID,
+		TaskID:     task.ID,
+		Outcome:    OutcomePlanned,
+	})
+
+	return true
+}
+
+// record adds a change to a task to the result, the journal and the audit log of the run
+func (p *pass) record(action journal.Action, externalID string, task omnifocus.Item, previous *omnifocus.TaskState) {
+	p.result.Changes = append(p.result.Changes, Change{
+		Source:     p.src.Name,
+		Action:     string(action),
+		Name:       task.Name,
+		ExternalID: externalID,
+		TaskID:     task.ID,
+		Outcome:    audit.OutcomeApplied,
+	})
+
+	warn("journal the change", p.Journal.Record(journal.Entry{
+		Source:     p.src.Name,
+		ExternalID: externalID,
+		Action:     action,
+		TaskID:     task.ID,
+		Name:       task.Name,
+		Previous:   previous,
+	}))
+
+	warn("audit the change", p.Audit.Operation(p.src.Name, string(action), externalID, task.ID, task.Name, audit.OutcomeApplied, nil))
+}
+
+// fail adds a change to a task that failed to the result and the audit log, and returns the error that
+// stops the source, since OmniFocus may be left in a state the rest of the sync doesn't expect
+func (p *pass) fail(action, externalID string, task omnifocus.Item, err error) error {
+	p.result.Changes = append(p.result.Changes, Change{
+		Source:     p.src.Name,
+		Action:     action,
+		Name:       task.Name,
+		ExternalID: externalID,
+		TaskID:     task.ID,
+		Outcome:    audit.OutcomeFailed,
+		Error:      err.Error(),
+	})
+
+	if !p.Options.DryRun {
+		warn("audit the change", p.Audit.Operation(p.src.Name, action, externalID, task.ID, task.Name, audit.OutcomeFailed, err))
+	}
+
+	return err
+}
+
+// skip adds a change that was held back to the result and the audit log
+func (p *pass) skip(action, externalID string, task omnifocus.Item, reason error) {
+	p.result.Changes = append(p.result.Changes, Change{
+		Source:     p.src.Name,
+		Action:     action,
+		Name:       task.Name,
+		ExternalID: externalID,
+		TaskID:     task.ID,
+		Outcome:    audit.OutcomeSkipped,
+		Error:      reason.Error(),
+	})
+
+	if !p.Options.DryRun {
+		warn("audit the change", p.Audit.Operation(p.src.Name, action, externalID, task.ID, task.Name, audit.OutcomeSkipped, reason))
+	}
+}
+
+// MARK: Public methods
+// Validate returns an error if the source is misconfigured in a way that would make syncing it unsafe
+func (r *Runner) Validate(src source.Source) error {
+	if _, err := src.MissingPolicy(); err != nil {
+		return err
+	}
+
+	for reason := range src.CloseActions {
+		if _, err := src.GetCloseAction(reason); err != nil {
+			return fmt.Errorf("%s: %w", src.Name, err)
+		}
+	}
+
+	if err := src.ValidateWriteback(); err != nil {
+		return err
+	}
+
+	if err := src.ValidateOwnership(); err != nil {
+		return err
+	}
+
+	if err := src.GetUnmatched(r.Settings.Unmatched).Validate(); err != nil {
+		return fmt.Errorf("%s: %w", src.Name, err)
+	}
+
+	return nil
+}
+
+// SyncSource brings the tasks of the source in OmniFocus into line with the items the source returns, or
+// only plans the changes in a dry run. The result holds every change, including the ones made before an error.
+func (r *Runner) SyncSource(src source.Source) (Result, error) {
+	result := Result{Source: src.Name, Changes: []Change{}}
+	p := &pass{Runner: r, src: src, result: &result}
+
+	err := p.sync()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, err
+}
+
+// SyncRecords brings the tasks of the given raw items of the source in line with them, leaving every other
+// task of the source alone, e.g. for the item a webhook reported. Items that were never synced may not pass
+// the filters of the source's URL, so the whole source is synced instead when there is one.
+func (r *Runner) SyncRecords(src source.Source, records []map[string]interface{}) (Result, error) {
+	if items, err := src.ParseRecords(records); err == nil {
+		for _, item := range items {
+			if _, ok := r.State.Get(src.Name, item.Note); !ok && !item.Closed && item.ParentNote == "" {
+				log.Printf("[runner] %s wasn't synced before, syncing all of %s so its filters apply", item.Name, src.Name)
+				return r.SyncSource(src)
+			}
+		}
+	}
+
+	result := Result{Source: src.Name, Changes: []Change{}}
+	p := &pass{Runner: r, src: src, result: &result, records: records}
+
+	err := p.sync()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result, err
+}
+
+// sync brings the tasks of the source in line with its items
+func (p *pass) sync() error {
+	src := p.src
+
+	if err := p.Validate(src); err != nil {
+		return fmt.Errorf("skipping source: %w", err)
+	}
+
+	policy, _ := src.MissingPolicy()
+
+	var items []omnifocus.NewOmniFocusItem
+	var snap *source.Snapshot
+	var err error
+	if p.records != nil {
+		items, err = src.ParseRecords(p.records)
+		if err != nil {
+			err = fmt.Errorf("failed to map the items of %s: %w", src.Name, err)
+		}
+	} else {
+		items, snap, err = src.GetItems(p.Fetch)
+	}
+
+	p.result.Fetched = len(items)
+	if !p.Options.DryRun {
+		warn("audit the fetch", p.Audit.Fetch(src.Name, len(items), err))
+	}
+	if err != nil {
+		// A source that couldn't be fetched must never complete tasks, so it is skipped entirely
+		return fmt.Errorf("skipping source: %w", err)
+	}
+
+	log.Printf("[runner] Desired state: %d\n", len(items))
+
+	if p.records != nil {
+		p.targets = map[string]bool{}
+		for _, item := range items {
+			p.targets[item.Note] = true
+			if r, ok := p.State.Get(src.Name, item.Note); ok && r.TaskID != "" {
+				p.targets[r.TaskID] = true
+			}
+		}
+	}
+
+	fallback := src.GetUnmatched(p.Settings.Unmatched)
+
+	// The source's own projects come first, so they win ties with the global ones
+	routing := append(append([]project.Project{}, src.Projects...), p.Projects...)
+
+	// routes holds every project an item was routed to, by name, with dynamic names already rendered
+	routes := map[string]project.Project{}
+
+	// inbox is set when any item goes to the Inbox
+	inbox := false
+
+	// parents holds the routed items by their note, so their subtasks follow them
+	parents := map[string]omnifocus.NewOmniFocusItem{}
+
+	routed := items[:0]
+	var unmatched []omnifocus.NewOmniFocusItem
+	for _, item := range items {
+		if item.ParentNote != "" {
+			parent, ok := parents[item.ParentNote]
+			if !ok {
+				// The parent was skipped, so there's no task to add the subtask under
+				continue
+			}
+
+			item.ProjectName = parent.ProjectName
+			item.Inbox = parent.Inbox
+			routed = append(routed, item)
+			continue
+		}
+
+		if src.Inbox {
+			item.Inbox = true
+			inbox = true
+			parents[item.Note] = item
+			routed = append(routed, item)
+			continue
+		}
+
+		match, trace, err := project.Route(item.Note, item.Fields, routing)
+		if p.Options.Explain && !item.Closed {
+			log.Printf("[runner] Routing %s:\n%s", item.Name, trace)
+		}
+
+		switch {
+		case err == nil && match.Inbox:
+			item.Inbox = true
+			inbox = true
+		case err == nil:
+			item.ProjectName = match.OFName
+			routes[match.OFName] = match
+		case item.Closed:
+			// Closed items only complete existing tasks, wherever they are
+		case fallback.Inbox:
+			item.Inbox = true
+			inbox = true
+		case fallback.Project != "":
+			item.ProjectName = fallback.Project
+		default:
+			unmatched = append(unmatched, item)
+			continue
+		}
+
+		parents[item.Note] = item
+		routed = append(routed, item)
+	}
+
+	// all keeps every routed item, so the records of the items outside the selected projects are kept
+	all := routed
+
+	items = []omnifocus.NewOmniFocusItem{}
+	for _, item := range all {
+		if item.Closed || p.selected(item.ProjectName, item.Inbox) {
+			items = append(items, item)
+		}
+	}
+
+	// Tasks in the Inbox are only found through the source's tags
+	if inbox && len(src.Tags) == 0 {
+		return fmt.Errorf("skipping source %s: adding items to the Inbox requires `Tags`", src.Name)
+	}
+
+	if len(unmatched) > 0 {
+		var b strings.Builder
+		for _, item := range unmatched {
+			fmt.Fprintf(&b, "\n  - %s (%s)", item.Name, item.Note)
+		}
+		log.Printf("[runner] Skipping %d items that don't match any project:%s", len(unmatched), b.String())
+	}
+
+	notes := make([]string, 0, len(items))
+	for _, item := range items {
+		notes = append(notes, item.Note)
+	}
+
+	// Closed tasks are only needed to reopen them or close their items upstream instead of adding duplicates
+	includeClosed := src.Reopen || src.Writeback != nil
+
+	var found []omnifocus.Item
+	if len(src.Tags) > 0 {
+		// Every task of a tagged source carries its tags, so the whole database is searched for them. Tasks
+		// the user moved to another project, nested under another task or filed from the Inbox are still
+		// tracked instead of being added again
+		found, err = omnifocus.FindItems(src.Tags, nil, includeClosed)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Without tags every task of the configured projects, the fallback project and the projects named by
+		// templates for this run's items belongs to the source
+		var projects []project.Project
+		for _, proj := range routing {
+			if proj.NameTemplate == "" && !proj.Inbox {
+				projects = append(projects, proj)
+			}
+		}
+
+		for _, proj := range routes {
+			projects = append(projects, proj)
+		}
+
+		if fallback.Project != "" {
+			projects = append(projects, project.Project{OFName: fallback.Project})
+		}
+
+		found, err = omnifocus.GetAllItems(projects, src.Tags, includeClosed)
+		if err != nil {
+			return err
+		}
+
+		// Tasks moved out of those projects are still found by the URL in their note, like the tasks of items
+		// that are no longer returned, which may be in a templated project no item was routed to this run
+		search := notes
+		if p.targets == nil {
+			returned := map[string]bool{}
+			for _, item := range all {
+				returned[item.Note] = true
+			}
+
+			search = append([]string{}, notes...)
+			for _, id := range p.State.ExternalIDs(src.Name) {
+				if !returned[id] {
+					search = append(search, id)
+				}
+			}
+		}
+
+		moved, err := omnifocus.FindItems(nil, search, includeClosed)
+		if err != nil {
+			return err
+		}
+
+		found = append(found, moved...)
+	}
+
+	var currentState []omnifocus.Item
+	for _, task := range found {
+		if p.selected(task.ProjectName, task.InInbox) && p.targeted(task) {
+			currentState = append(currentState, task)
+		}
+	}
+
+	// The last synced state matches renamed tasks with their items and keeps tasks removed in OmniFocus removed
+	comparison := p.State.Compare(src.Name, items, currentState)
+
+	current := toSet(comparison.Current)
+	log.Printf("[runner] Current state: %d\n", len(current))
+
+	d := delta.Delta(toSetSource(comparison.Desired), current)
+
+	// Tasks that merely carry the source's tags or share its projects, like the ones made by hand, were never
+	// synced from it and are left alone
+	d = p.owned(d)
+
+	// Tasks whose item merely vanished are only completed or dropped when the source's policy says so
+	var orphans []delta.Operation
+	if policy == source.MissingTag || policy == source.MissingIgnore {
+		for _, op := range d {
+			if op.Type == delta.Remove {
+				orphans = append(orphans, op)
+			}
+		}
+
+		d = delta.Without(d, delta.Remove)
+		log.Printf("[runner] Found %d tasks no longer returned by the source", len(orphans))
+	}
+
+	var guardErr error
+	if err := src.Limits(p.Options.Limits).Check(d, countOpen(current)); err != nil {
+		if p.Options.Force {
+			log.Printf("[runner] Ignoring safety limit because of --force: %s", err)
+		} else {
+			guardErr = fmt.Errorf("not completing any tasks for %s: %w. Run with --force to complete them anyway", src.Name, err)
+			log.Printf("[runner] %s", guardErr)
+			for _, op := range d {
+				if op.Type == delta.Remove || op.Type == delta.Complete {
+					externalID := ""
+					if desired, ok := op.Desired.(*omnifocus.NewOmniFocusItem); ok {
+						externalID = desired.Note
+					}
+					p.skip(string(journal.ActionComplete), externalID, *(op.Item.(*omnifocus.Item)), err)
+				}
+			}
+			d = delta.Without(d, delta.Remove, delta.Complete)
+		}
+	}
+
+	if src.Writeback != nil {
+		d = p.writeback(d)
+	}
+
+	// Subtasks are added under the task of their parent, which may be added in the same run
+	sort.SliceStable(d, func(i, j int) bool {
+		return !isSubtask(d[i]) && isSubtask(d[j])
+	})
+
+	// tasks holds the task of every item after the changes, by key
+	tasks := map[string]omnifocus.Item{}
+	for k := range current {
+		tasks[k.Key()] = *(k.(*omnifocus.Item))
+	}
+
+	// keys holds the key of every item by its note, so subtasks are added under the task of their parent
+	keys := map[string]string{}
+	for _, item := range comparison.Desired {
+		keys[item.Note] = item.Key()
+	}
+
+	log.Printf("[runner] Found %d changes to apply", len(d))
+	for _, d := range d {
+		switch d.Type {
+		case delta.Add:
+			item := *(d.Item.(*omnifocus.NewOmniFocusItem))
+			if p.planned(string(journal.ActionAdd), item.Note, omnifocus.Item{Name: item.Name}) {
+				continue
+			}
+
+			if item.ParentNote != "" {
+				parent, ok := tasks[keys[item.ParentNote]]
+				if !ok || parent.IsClosed() {
+					log.Printf("[runner] Skipping %s, the task of its parent is closed or was removed", item.Name)
+					continue
+				}
+				item.ParentID = parent.ID
+			}
+
+			if route, ok := routes[item.ProjectName]; ok && !item.Inbox {
+				err := p.ensureProject(route)
+				if err != nil {
+					return p.fail(string(journal.ActionAdd), item.Note, omnifocus.Item{Name: item.Name}, err)
+				}
+			}
+
+			added, err := omnifocus.AddItem(item)
+			if err != nil {
+				return p.fail(string(journal.ActionAdd), item.Note, omnifocus.Item{Name: item.Name}, err)
+			}
+
+			tasks[item.Key()] = added
+			p.record(journal.ActionAdd, item.Note, added, nil)
+		case delta.Complete:
+			item := *(d.Item.(*omnifocus.Item))
+			desired := d.Desired.(*omnifocus.NewOmniFocusItem)
+			action, _ := src.GetCloseAction(desired.CloseReason)
+			entry, apply := journal.ActionComplete, omnifocus.CompleteItem
+			switch action {
+			case source.CloseDrop:
+				entry, apply = journal.ActionDrop, omnifocus.DropItem
+			case source.CloseDelete:
+				entry, apply = journal.ActionDelete, omnifocus.DeleteItem
+			}
+			if p.planned(string(entry), desired.Note, item) {
+				continue
+			}
+
+			if err := apply(item); err != nil {
+				return p.fail(string(entry), desired.Note, item, err)
+			}
+
+			previous := item.State()
+			p.record(entry, desired.Note, item, &previous)
+		case delta.Reopen:
+			item := *(d.Item.(*omnifocus.Item))
+			note := d.Desired.(*omnifocus.NewOmniFocusItem).Note
+			if p.planned(string(journal.ActionReopen), note, item) {
+				continue
+			}
+
+			if err := omnifocus.ReopenItem(item); err != nil {
+				return p.fail(string(journal.ActionReopen), note, item, err)
+			}
+
+			previous := item.State()
+			p.record(journal.ActionReopen, note, item, &previous)
+		case delta.Remove:
+			item := *(d.Item.(*omnifocus.Item))
+			entry, apply := journal.ActionComplete, omnifocus.CompleteItem
+			switch policy {
+			case source.MissingDrop:
+				entry, apply = journal.ActionDrop, omnifocus.DropItem
+			case source.MissingDelete:
+				entry, apply = journal.ActionDelete, omnifocus.DeleteItem
+			}
+			if p.planned(string(entry), "", item) {
+				continue
+			}
+
+			if err := apply(item); err != nil {
+				return p.fail(string(entry), "", item, err)
+			}
+
+			previous := item.State()
+			p.record(entry, "", item, &previous)
+		}
+	}
+
+	if policy == source.MissingTag {
+		tag := src.GetOrphanTag()
+		for _, op := range orphans {
+			item := *(op.Item.(*omnifocus.Item))
+			if item.HasTag(tag) || p.planned(string(journal.ActionTag), "", item) {
+				continue
+			}
+
+			err := omnifocus.TagItem(item, tag)
+			if err != nil {
+				return p.fail(string(journal.ActionTag), "", item, err)
+			}
+
+			previous := item.State()
+			p.record(journal.ActionTag, "", item, &previous)
+		}
+
+		// Items returned again lose the tag their task got while they were missing
+		for _, item := range reclaimed(comparison.Desired, tasks, tag) {
+			task := tasks[item.Key()]
+			if p.planned(string(journal.ActionUntag), item.Note, task) {
+				continue
+			}
+
+			if err := omnifocus.UntagItem(task, tag); err != nil {
+				return p.fail(string(journal.ActionUntag), item.Note, task, err)
+			}
+
+			previous := task.State()
+			p.record(journal.ActionUntag, item.Note, task, &previous)
+
+			task.Tags = without(task.Tags, tag)
+			tasks[item.Key()] = task
+		}
+	}
+
+	if err := p.merge(comparison.Desired, tasks); err != nil {
+		return err
+	}
+
+	if src.ForceProject {
+		if err := p.moveBack(items, current, routes); err != nil {
+			return err
+		}
+	}
+
+	if p.Options.DryRun {
+		return guardErr
+	}
+
+	if p.targets != nil {
+		p.State.UpdateItems(src.Name, all, tasks, time.Now())
+	} else {
+		p.State.Update(src.Name, all, tasks, time.Now())
+	}
+	for note, record := range p.held {
+		if record == nil {
+			p.State.Delete(src.Name, note)
+		} else {
+			p.State.Put(src.Name, *record)
+		}
+	}
+	if err := p.State.Save(); err != nil {
+		log.Printf("[runner] Failed to save the sync state: %s", err)
+	}
+
+	// The watermark only moves once the items closed since the last run had their tasks closed, so items held
+	// back by a safety limit are fetched again on the next run
+	if guardErr == nil {
+		if err := snap.Save(); err != nil {
+			log.Printf("[runner] Failed to save the snapshot of %s: %s", src.Name, err)
+		}
+	}
+
+	return guardErr
+}
+
+// writeback closes the items upstream whose tasks were completed or dropped in OmniFocus, and returns the
+// operations that are still to be applied. Tasks are only reopened when the source's conflict policy lets
+// the upstream item win, or for subtasks when the source reopens tasks.
+func (p *pass) writeback(ops []delta.Operation) []delta.Operation {
+	src := p.src
+	policy := src.GetConflictPolicy()
+
+	r := []delta.Operation{}
+	for _, op := range ops {
+		if op.Type != delta.Reopen {
+			r = append(r, op)
+			continue
+		}
+
+		task := op.Item.(*omnifocus.Item)
+		item := op.Desired.(*omnifocus.NewOmniFocusItem)
+		if item.ParentNote != "" {
+			if src.Reopen {
+				r = append(r, op)
+			}
+			continue
+		}
+
+		// Only a task closed after its item was last synced was closed in OmniFocus since. One that was already
+		// closed then, or without a close time to tell, means the item was reopened upstream
+		conflict := src.Conflicts(*item, task.ClosedAt)
+		if record, ok := p.State.Get(src.Name, item.Note); !ok || task.ClosedAt.IsZero() || !task.ClosedAt.After(record.SyncedAt) {
+			conflict = true
+		}
+
+		if conflict {
+			switch policy {
+			case source.ConflictUpstream:
+				log.Printf("[runner] %s was updated upstream after its task was closed, reopening it", item.Name)
+				r = append(r, op)
+				continue
+			case source.ConflictSkip:
+				log.Printf("[runner] %s was updated upstream after its task was closed, leaving both alone", item.Name)
+				p.hold(item.Note)
+				continue
+			}
+		}
+
+		if p.planned(ActionWriteback, item.Note, *task) {
+			continue
+		}
+
+		// A failed write back leaves the task closed, so it is tried again on the next run
+		err := src.CloseUpstream(*item, task.Dropped)
+		if err != nil {
+			log.Printf("[runner] %s", err)
+			_ = p.fail(ActionWriteback, item.Note, *task, err)
+			p.hold(item.Note)
+			continue
+		}
+
+		p.result.Changes = append(p.result.Changes, Change{
+			Source:     src.Name,
+			Action:     ActionWriteback,
+			Name:       task.Name,
+			ExternalID: item.Note,
+			TaskID:     task.ID,
+			Outcome:    audit.OutcomeApplied,
+		})
+		warn("audit the change", p.Audit.Operation(src.Name, ActionWriteback, item.Note, task.ID, task.Name, audit.OutcomeApplied, nil))
+	}
+
+	return r
+}
+
+// merge brings the fields of the tasks in line with their items, keeping the fields changed in OmniFocus
+// that didn't change upstream since the last sync. Tasks that were just added or never synced are left alone.
+func (p *pass) merge(items []omnifocus.NewOmniFocusItem, tasks map[string]omnifocus.Item) error {
+	for _, item := range items {
+		task, ok := tasks[item.Key()]
+		if item.Closed || !ok || task.IsClosed() {
+			continue
+		}
+
+		record, ok := p.State.Get(p.src.Name, item.Note)
+		if !ok || record.TaskID != task.ID {
+			continue
+		}
+
+		update, changed := record.Merge(item, task, p.src.GetOwnership)
+		if !changed || p.planned(string(journal.ActionUpdate), item.Note, task) {
+			continue
+		}
+
+		err := omnifocus.UpdateItem(task, update)
+		if err != nil {
+			return p.fail(string(journal.ActionUpdate), item.Note, task, err)
+		}
+
+		previous := task.State()
+		p.record(journal.ActionUpdate, item.Note, task, &previous)
+	}
+
+	return nil
+}
+
+// moveBack moves the open tasks the user moved out of the project or the Inbox their item is routed to back
+// to it. Tasks nested under another task of the right project are left where they are.
+func (p *pass) moveBack(items []omnifocus.NewOmniFocusItem, current map[delta.Keyed]struct{}, routes map[string]project.Project) error {
+	byKey := map[string]*omnifocus.Item{}
+	for k := range current {
+		byKey[k.Key()] = k.(*omnifocus.Item)
+	}
+
+	for _, item := range items {
+		task, ok := byKey[item.Key()]
+		if item.Closed || item.ParentNote != "" || !ok || task.IsClosed() {
+			continue
+		}
+
+		if item.Inbox {
+			if task.InInbox {
+				continue
+			}
+		} else if item.ProjectName == "" || (task.ProjectName == item.ProjectName && !task.InInbox) {
+			continue
+		}
+
+		if p.planned(string(journal.ActionMove), item.Note, *task) {
+			continue
+		}
+
+		if route, ok := routes[item.ProjectName]; ok && !item.Inbox {
+			err := p.ensureProject(route)
+			if err != nil {
+				return p.fail(string(journal.ActionMove), item.Note, *task, err)
+			}
+		}
+
+		err := omnifocus.MoveItem(*task, item.ProjectName, item.Inbox)
+		if err != nil {
+			return p.fail(string(journal.ActionMove), item.Note, *task, err)
+		}
+
+		previous := task.State()
+		p.record(journal.ActionMove, item.Note, *task, &previous)
+	}
+
+	return nil
+}
+
+// toSet returns the items as a set. When an open and a closed item share a key, the open one is kept,
+// so a task that was completed and later added again isn't reopened.
+func toSet(l []omnifocus.Item) map[delta.Keyed]struct{} {
+	byKey := map[string]*omnifocus.Item{}
+	for _, i := range l {
+		if existing, ok := byKey[i.Key()]; ok && !existing.IsClosed() {
+			continue
+		}
+
+		// need to clone because range reuses `i` for each item!
+		byKey[i.Key()] = &omnifocus.Item{
+			ID:          i.ID,
+			Name:        i.Name,
+			Tags:        i.Tags,
+			Completed:   i.Completed,
+			Dropped:     i.Dropped,
+			Note:        i.Note,
+			DueDate:     i.DueDate,
+			ClosedAt:    i.ClosedAt,
+			SyncKey:     i.SyncKey,
+			ProjectName: i.ProjectName,
+			InInbox:     i.InInbox,
+		}
+	}
+
+	r := map[delta.Keyed]struct{}{}
+	for _, i := range byKey {
+		r[i] = struct{}{}
+	}
+	return r
+}
+
+// reclaimed returns the open items whose open task carries the orphan tag, because the item was missing from
+// an earlier run
+func reclaimed(items []omnifocus.NewOmniFocusItem, tasks map[string]omnifocus.Item, tag string) []omnifocus.NewOmniFocusItem {
+	var r []omnifocus.NewOmniFocusItem
+	for _, item := range items {
+		task, ok := tasks[item.Key()]
+		if !item.Closed && ok && !task.IsClosed() && task.HasTag(tag) {
+			r = append(r, item)
+		}
+	}
+
+	return r
+}
+
+// without returns the tags without the given one
+func without(tags []string, tag string) []string {
+	r := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t != tag {
+			r = append(r, t)
+		}
+	}
+
+	return r
+}
+
+// isSubtask returns whether the operation adds a subtask
+func isSubtask(op delta.Operation) bool {
+	item, ok := op.Item.(*omnifocus.NewOmniFocusItem)
+	return ok && op.Type == delta.Add && item.ParentNote != ""
+}
+
+// countOpen returns the number of items in the set that are neither completed nor dropped
+func countOpen(items map[delta.Keyed]struct{}) int {
+	n := 0
+	for i := range items {
+		if !i.(*omnifocus.Item).IsClosed() {
+			n++
+		}
+	}
+	return n
+}
+
+func toSetSource(l []omnifocus.NewOmniFocusItem) map[delta.Keyed]struct{} {
+	r := map[delta.Keyed]struct{}{}
+	for _, i := range l {
+		// need to clone because range reuses `i` for each item!
+		r[&omnifocus.NewOmniFocusItem{
+			Name:        i.Name,
+			ProjectName: i.ProjectName,
+			Inbox:       i.Inbox,
+			Tags:        i.Tags,
+			Note:        i.Note,
+			DueDateMS:   i.DueDateMS,
+			ParentNote:  i.ParentNote,
+			Closed:      i.Closed,
+			CloseReason: i.CloseReason,
+			Fields:      i.Fields,
+		}] = struct{}{}
+	}
+	return r
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
+)
+
+// MARK: Runner tests
+// Tests that only the selected projects and the Inbox are synced, and every one without a selection
+func TestSelected(t *testing.T) {
+	r := &Runner{}
+	if !r.selected("Work", false) || !r.selected("", true) {
+		t.Errorf("Expected everything to be selected without projects")
+	}
+
+	r.Options.Projects = []string{"Work", InboxProject}
+	tests := []struct {
+		project  string
+		inbox    bool
+		expected bool
+	}{
+		{"Work", false, true},
+		{"Home", false, false},
+		{"", true, true},
+		{"Home", true, true},
+	}
+
+	for _, test := range tests {
+		if got := r.selected(test.project, test.inbox); got != test.expected {
+			t.Errorf("Expected %s (inbox: %t) to be selected: %t, was: %t", test.project, test.inbox, test.expected, got)
+		}
+	}
+}
+
+// Tests that the open task is kept when an open and a closed task share a key
+func TestToSet(t *testing.T) {
+	set := toSet([]omnifocus.Item{
+		{ID: "a1", Name: "[1] One", Completed: true},
+		{ID: "a2", Name: "[1] One"},
+		{ID: "a3", Name: "[1] One", Dropped: true},
+	})
+
+	if len(set) != 1 {
+		t.Fatalf("Expected 1 task, was: %d", len(set))
+	}
+
+	for k := range set {
+		if task := k.(*omnifocus.Item); task.ID != "a2" {
+			t.Errorf("Expected the open task, was: %+v", task)
+		}
+	}
+}
+
+// Tests that a targeted sync only covers the tasks of its items, by their note or their last synced task
+func TestTargeted(t *testing.T) {
+	p := &pass{}
+	if !p.targeted(omnifocus.Item{ID: "a9"}) {
+		t.Errorf("Expected every task to be targeted without targets")
+	}
+
+	p.targets = map[string]bool{"https://github.com/owner/repo/issues/1": true, "a2": true}
+	tests := []struct {
+		task     omnifocus.Item
+		expected bool
+	}{
+		{omnifocus.Item{ID: "a1", Note: "https://github.com/owner/repo/issues/1\nmy notes"}, true},
+		{omnifocus.Item{ID: "a2", Note: "moved the link"}, true},
+		{omnifocus.Item{ID: "a3", Note: "https://github.com/owner/repo/issues/3"}, false},
+	}
+
+	for _, test := range tests {
+		if got := p.targeted(test.task); got != test.expected {
+			t.Errorf("Expected %s to be targeted: %t, was: %t", test.task.ID, test.expected, got)
+		}
+	}
+}
+
+// Tests that tasks carrying the tags of a source are only removed when they link to an item of the source
+func TestOwned(t *testing.T) {
+	p := &pass{src: source.Source{Name: "GitHub", URL: "https://api.github.com/issues", Tags: []string{"github"}}}
+	synced := &omnifocus.Item{ID: "a1", Name: "[1] One", Note: "https://github.com/owner/repo/issues/1"}
+	byHand := &omnifocus.Item{ID: "a2", Name: "Review the GitHub bill", Tags: []string{"github"}}
+	added := &omnifocus.NewOmniFocusItem{Name: "[2] Two", Note: "https://github.com/owner/repo/issues/2"}
+
+	ops := p.owned([]delta.Operation{
+		{Type: delta.Remove, Item: synced},
+		{Type: delta.Remove, Item: byHand},
+		{Type: delta.Add, Item: added},
+	})
+
+	if len(ops) != 2 || ops[0].Item != synced || ops[1].Item != added {
+		t.Errorf("Unexpected operations: %+v", ops)
+	}
+}
+
+// Tests that a source without tags only completes or removes the tasks of its project that it has a record of
+func TestOwnedUntagged(t *testing.T) {
+	store, err := state.Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	store.Put("GitHub", state.Record{ExternalID: "https://github.com/owner/repo/issues/1", TaskID: "a1"})
+	store.Put("GitHub", state.Record{ExternalID: "https://github.com/owner/repo/issues/3", TaskID: "a3"})
+
+	p := &pass{Runner: &Runner{State: store}, src: source.Source{Name: "GitHub", URL: "https://api.github.com/issues"}}
+	synced := &omnifocus.Item{ID: "a1", Name: "[1] One", Note: "https://github.com/owner/repo/issues/1", ProjectName: "OmniSync"}
+	closed := &omnifocus.Item{ID: "a3", Name: "[3] Three", Note: "https://github.com/owner/repo/issues/3\nmy notes", ProjectName: "OmniSync"}
+	byHand := &omnifocus.Item{ID: "a2", Name: "Plan the release", ProjectName: "OmniSync"}
+	linked := &omnifocus.Item{ID: "a4", Name: "[4] Four", Note: "https://github.com/owner/repo/issues/4", ProjectName: "OmniSync"}
+
+	ops := p.owned([]delta.Operation{
+		{Type: delta.Remove, Item: synced},
+		{Type: delta.Complete, Item: closed, Desired: &omnifocus.NewOmniFocusItem{Name: "[3] Three", Closed: true}},
+		{Type: delta.Remove, Item: byHand},
+		{Type: delta.Complete, Item: byHand, Desired: &omnifocus.NewOmniFocusItem{Name: "Plan the release", Closed: true}},
+		{Type: delta.Remove, Item: linked},
+	})
+
+	if len(ops) != 2 || ops[0].Item != synced || ops[1].Item != closed {
+		t.Errorf("Unexpected operations: %+v", ops)
+	}
+}
+
+// Tests that only tasks closed after their item was last synced are written back, and every other one is
+// reopened because its item was reopened upstream
+func TestWriteback(t *testing.T) {
+	store, err := state.Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	synced := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	store.Put("GitHub", state.Record{ExternalID: "https://github.com/owner/repo/issues/1", TaskID: "a1", SyncedAt: synced})
+	store.Put("GitHub", state.Record{ExternalID: "https://github.com/owner/repo/issues/2", TaskID: "a2", SyncedAt: synced})
+
+	p := &pass{
+		Runner: &Runner{State: store, Options: Options{DryRun: true}},
+		src:    source.Source{Name: "GitHub", Writeback: &source.Writeback{Adapter: source.AdapterGitHub}},
+		result: &Result{},
+	}
+
+	reopen := func(id, note string, closedAt time.Time) delta.Operation {
+		return delta.Operation{
+			Type:    delta.Reopen,
+			Item:    &omnifocus.Item{ID: id, Name: id, Completed: true, ClosedAt: closedAt},
+			Desired: &omnifocus.NewOmniFocusItem{Name: id, Note: note},
+		}
+	}
+
+	ops := p.writeback([]delta.Operation{
+		reopen("a1", "https://github.com/owner/repo/issues/1", synced.Add(time.Hour)),
+		reopen("a2", "https://github.com/owner/repo/issues/2", synced.Add(-time.Hour)),
+		reopen("a3", "https://github.com/owner/repo/issues/3", synced.Add(time.Hour)),
+		reopen("a4", "https://github.com/owner/repo/issues/1", time.Time{}),
+	})
+
+	if len(p.result.Changes) != 1 || p.result.Changes[0].TaskID != "a1" || p.result.Changes[0].Action != ActionWriteback {
+		t.Errorf("Expected only a1 to be written back, was: %+v", p.result.Changes)
+	}
+
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 tasks to be reopened, was: %+v", ops)
+	}
+
+	for i, id := range []string{"a2", "a3", "a4"} {
+		if task := ops[i].Item.(*omnifocus.Item); task.ID != id {
+			t.Errorf("Expected %s to be reopened, was: %s", id, task.ID)
+		}
+	}
+}
+
+// Tests that only the open tasks of returned open items lose the orphan tag
+func TestReclaimed(t *testing.T) {
+	items := []omnifocus.NewOmniFocusItem{
+		{Name: "[1] One", Note: "https://github.com/owner/repo/issues/1"},
+		{Name: "[2] Two", Note: "https://github.com/owner/repo/issues/2"},
+		{Name: "[3] Three", Note: "https://github.com/owner/repo/issues/3", Closed: true},
+		{Name: "[4] Four", Note: "https://github.com/owner/repo/issues/4"},
+		{Name: "[5] Five", Note: "https://github.com/owner/repo/issues/5"},
+	}
+	tasks := map[string]omnifocus.Item{
+		"[1] One":   {ID: "a1", Name: "[1] One", Tags: []string{"github", "orphaned"}},
+		"[2] Two":   {ID: "a2", Name: "[2] Two", Tags: []string{"github"}},
+		"[3] Three": {ID: "a3", Name: "[3] Three", Tags: []string{"orphaned"}},
+		"[4] Four":  {ID: "a4", Name: "[4] Four", Tags: []string{"orphaned"}, Completed: true},
+	}
+
+	got := reclaimed(items, tasks, "orphaned")
+	if len(got) != 1 || got[0].Name != "[1] One" {
+		t.Errorf("Expected only [1] One to be reclaimed, was: %+v", got)
+	}
+
+	if tags := without(tasks["[1] One"].Tags, "orphaned"); len(tags) != 1 || tags[0] != "github" {
+		t.Errorf("Unexpected tags: %v", tags)
+	}
+}
@@ -0,0 +1,186 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed cron expression: `minute hour day-of-month month day-of-week`
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day of the month and the day of the week were restricted. When both are, a day matching
+	// either one matches, like in cron
+	domSet, dowSet bool
+}
+
+// cronField is the range of a field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField    = cronField{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// macros are the shorthands for common expressions
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// MARK: Private helper methods
+// value parses a number or name of the field
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s `%s`, expected %d to %d", f.name, s, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+// parse returns the bits of the values the field matches, and whether it is restricted
+func (f cronField) parse(s string) (uint64, bool, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n <= 0 {
+				return 0, false, fmt.Errorf("invalid step `%s` of the %s", after, f.name)
+			}
+
+			rng, step = before, n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, false, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, false, err
+			}
+			if lo > hi {
+				return 0, false, fmt.Errorf("invalid %s range `%s`", f.name, rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, false, err
+			}
+
+			// `5/15` runs from 5 to the end of the range
+			if step > 1 {
+				hi = f.max
+			} else {
+				hi = lo
+			}
+		}
+
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, s != "*", nil
+}
+
+// parseCron parses a cron expression of five fields, or one of the `@daily` style macros
+func parseCron(expr string) (cron, error) {
+	if macro, ok := macros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cron{}, fmt.Errorf("invalid cron expression `%s`, expected five fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	var c cron
+	var err error
+	if c.minute, _, err = minuteField.parse(fields[0]); err != nil {
+		return cron{}, err
+	}
+	if c.hour, _, err = hourField.parse(fields[1]); err != nil {
+		return cron{}, err
+	}
+	if c.dom, c.domSet, err = domField.parse(fields[2]); err != nil {
+		return cron{}, err
+	}
+	if c.month, _, err = monthField.parse(fields[3]); err != nil {
+		return cron{}, err
+	}
+	if c.dow, c.dowSet, err = dowField.parse(fields[4]); err != nil {
+		return cron{}, err
+	}
+
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// has returns whether the bit of the value is set
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// matchesDay returns whether the day of the time matches the expression
+func (c cron) matchesDay(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if c.domSet && c.dowSet {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// next returns the first time after t the expression matches, or the zero time when it never does
+func (c cron) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Every day of five years covers every leap day, so an expression that matches none of them never matches
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
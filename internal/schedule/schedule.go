@@ -0,0 +1,145 @@
+// Package schedule says when the sources are synced in daemon mode: on an interval or a cron expression,
+// with jitter so sources don't all hit their APIs at once, and backing off after failures.
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultEvery is the interval of sources without a schedule
+	DefaultEvery = 15 * time.Minute
+	// baseBackoff is the delay after the first failure, doubled for every following one
+	baseBackoff = time.Minute
+	// defaultMaxBackoff caps the backoff when a schedule doesn't set its own
+	defaultMaxBackoff = time.Hour
+)
+
+// jitter returns a random duration below the maximum. It is replaced in tests to make schedules deterministic.
+var jitter = func(max time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// Schedule says when a source is synced
+type Schedule struct {
+	// How often the source is synced, as a Go duration, e.g. `15m`
+	Every string `json:"Every"`
+	// When the source is synced, as a cron expression, e.g. `*/10 8-18 * * mon-fri`. Used instead of `Every`
+	Cron string `json:"Cron"`
+	// The longest random delay added to every sync, as a Go duration, e.g. `30s`
+	Jitter string `json:"Jitter"`
+	// The longest wait after failures, as a Go duration. The wait starts at a minute and doubles with every
+	// failure in a row. Defaults to `1h`
+	MaxBackoff string `json:"MaxBackoff"`
+}
+
+// MARK: Private helper methods
+// duration parses the named duration of the schedule, which must be positive when set
+func duration(name, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid `%s`: %w", name, err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid `%s`: `%s` isn't positive", name, s)
+	}
+
+	return d, nil
+}
+
+// MARK: Public methods
+// IsSet returns whether the schedule says when to sync
+func (s Schedule) IsSet() bool {
+	return s.Every != "" || s.Cron != ""
+}
+
+// Validate returns an error if the schedule can't be evaluated
+func (s Schedule) Validate() error {
+	if s.Every != "" && s.Cron != "" {
+		return fmt.Errorf("a schedule can only set one of `Every` or `Cron`")
+	}
+
+	for _, d := range []struct{ name, value string }{{"Every", s.Every}, {"Jitter", s.Jitter}, {"MaxBackoff", s.MaxBackoff}} {
+		if _, err := duration(d.name, d.value); err != nil {
+			return err
+		}
+	}
+
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return err
+		}
+
+		if c.next(time.Now()).IsZero() {
+			return fmt.Errorf("the cron expression `%s` never matches", s.Cron)
+		}
+	}
+
+	return nil
+}
+
+// Next returns when the source is synced next after the given time, with jitter. A schedule that isn't set
+// syncs every DefaultEvery.
+func (s Schedule) Next(after time.Time) (time.Time, error) {
+	var next time.Time
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		next = c.next(after)
+		if next.IsZero() {
+			return time.Time{}, fmt.Errorf("the cron expression `%s` never matches", s.Cron)
+		}
+	} else {
+		every, err := duration("Every", s.Every)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if every == 0 {
+			every = DefaultEvery
+		}
+
+		next = after.Add(every)
+	}
+
+	max, err := duration("Jitter", s.Jitter)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if max > 0 {
+		next = next.Add(jitter(max))
+	}
+
+	return next, nil
+}
+
+// Backoff returns how long to wait after the given number of failures in a row
+func (s Schedule) Backoff(failures int) time.Duration {
+	max, err := duration("MaxBackoff", s.MaxBackoff)
+	if err != nil || max == 0 {
+		max = defaultMaxBackoff
+	}
+
+	delay := baseBackoff
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// MARK: Next tests
+// Tests when intervals and cron expressions are due next
+func TestNext(t *testing.T) {
+	// A Wednesday
+	start := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		schedule Schedule
+		expected time.Time
+	}{
+		{Schedule{}, start.Add(DefaultEvery)},
+		{Schedule{Every: "90s"}, start.Add(90 * time.Second)},
+		{Schedule{Cron: "*/10 * * * *"}, time.Date(2024, 5, 1, 12, 40, 0, 0, time.UTC)},
+		{Schedule{Cron: "0 9-17 * * mon-fri"}, time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{Schedule{Cron: "30 8 * * sat,sun"}, time.Date(2024, 5, 4, 8, 30, 0, 0, time.UTC)},
+		{Schedule{Cron: "0 0 * * 7"}, time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{Schedule{Cron: "@monthly"}, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Schedule{Cron: "0 12 29 feb *"}, time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// Either day matches when both are restricted
+		{Schedule{Cron: "0 0 15 * fri"}, time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		next, err := test.schedule.Next(start)
+		if err != nil {
+			t.Fatalf("Unexpected err for %+v: %s", test.schedule, err)
+		}
+
+		if !next.Equal(test.expected) {
+			t.Errorf("Expected %+v to be due at %s, was: %s", test.schedule, test.expected, next)
+		}
+	}
+}
+
+// Tests that jitter delays the next time by up to its maximum
+func TestNextJitter(t *testing.T) {
+	original := jitter
+	defer func() { jitter = original }()
+
+	jitter = func(max time.Duration) time.Duration {
+		return max - time.Second
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	next, err := Schedule{Every: "1m", Jitter: "30s"}.Next(start)
+	if err != nil || !next.Equal(start.Add(89*time.Second)) {
+		t.Errorf("Unexpected next time: %s, err: %v", next, err)
+	}
+}
+
+// MARK: Validate tests
+// Tests that invalid schedules are rejected
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		expected string
+	}{
+		{Schedule{Every: "1h", Cron: "@daily"}, "a schedule can only set one of `Every` or `Cron`"},
+		{Schedule{Every: "soon"}, "invalid `Every`: time: invalid duration \"soon\""},
+		{Schedule{Jitter: "-1s"}, "invalid `Jitter`: `-1s` isn't positive"},
+		{Schedule{Cron: "* * *"}, "invalid cron expression `* * *`, expected five fields: minute hour day-of-month month day-of-week"},
+		{Schedule{Cron: "60 * * * *"}, "invalid minute `60`, expected 0 to 59"},
+		{Schedule{Cron: "0 0 31 feb *"}, "the cron expression `0 0 31 feb *` never matches"},
+		{Schedule{Cron: "*/0 * * * *"}, "invalid step `0` of the minute"},
+	}
+
+	for _, test := range tests {
+		if err := test.schedule.Validate(); err == nil || err.Error() != test.expected {
+			t.Errorf("Unexpected err for %+v: %v", test.schedule, err)
+		}
+	}
+
+	if err := (Schedule{Cron: "*/15 8-18 * jan-jun 1-5", Jitter: "1m", MaxBackoff: "2h"}).Validate(); err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+}
+
+// MARK: Backoff tests
+// Tests that the backoff doubles with every failure up to its maximum
+func TestBackoff(t *testing.T) {
+	s := Schedule{}
+	for failures, expected := range []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if got := s.Backoff(failures); got != expected {
+			t.Errorf("Expected a backoff of %s after %d failures, was: %s", expected, failures, got)
+		}
+	}
+
+	if got := s.Backoff(20); got != time.Hour {
+		t.Errorf("Expected the backoff to be capped at an hour, was: %s", got)
+	}
+}
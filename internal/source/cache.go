@@ -0,0 +1,114 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+)
+
+// Cache stores the last successful response of every source URL on disk, so the next run can send a
+// conditional request and reuse the stored response when the source answers `304 Not Modified`.
+type Cache struct {
+	// The directory the responses are stored in
+	Dir string
+}
+
+// cacheEntry is a single response stored in the cache
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+	Body         []byte `json:"body"`
+}
+
+// NewCache returns a cache that stores its responses in the given directory
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// MARK: Private helper methods
+// path returns the file the response for the given url is stored in
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return path.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the stored response for the given url, or nil when there is none
+func (c *Cache) load(url string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	err = json.Unmarshal(bytes, &entry)
+	if err != nil || entry.URL != url {
+		log.Printf("[source] Ignoring unreadable cache entry for %s", url)
+		return nil
+	}
+
+	return &entry
+}
+
+// store saves the response for the given url if it carries a validator that can be sent back later
+func (c *Cache) store(url string, header http.Header, body []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	entry := cacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Body:         body,
+	}
+
+	if entry.ETag == "" && entry.LastModified == "" {
+		return nil
+	}
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	err = os.MkdirAll(c.Dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Responses can contain private data, so they are only readable by the user
+	err = os.WriteFile(c.path(url), bytes, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// conditionalHeader returns the headers that make a request conditional on the stored response
+func (entry *cacheEntry) conditionalHeader() http.Header {
+	header := http.Header{}
+	if entry == nil {
+		return header
+	}
+
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+
+	return header
+}
@@ -0,0 +1,64 @@
+package source
+
+import (
+	"net/http"
+	"testing"
+)
+
+// MARK: Cache tests
+// Tests that a `304 Not Modified` response reuses the cached items
+func TestGetItemsRevalidatesCache(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Fatalf("Unexpected conditional request")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 14 Nov 2023 22:13:20 GMT")
+		status(200, itemsBody)(w, r)
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"v1"` || r.Header.Get("If-Modified-Since") != "Tue, 14 Nov 2023 22:13:20 GMT" {
+			t.Fatalf("Expected a conditional request, got headers: %v", r.Header)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	cache := NewCache(t.TempDir())
+	source := Source{Name: "Cached", URL: server.URL, Response: source1.Response}
+
+	for i := 0; i < 2; i++ {
+		items, _, err := source.GetItems(Options{Cache: cache})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(items) != 1 || items[0].Name != "[1] title" {
+			t.Fatalf("Unexpected items on run %d: %v", i+1, items)
+		}
+	}
+
+	if *calls != 2 {
+		t.Fatalf("Expected 2 calls, was %d", *calls)
+	}
+}
+
+// Tests that responses without validators aren't cached
+func TestGetItemsWithoutValidators(t *testing.T) {
+	stubSleep(t)
+	unconditional := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			t.Fatalf("Unexpected conditional request")
+		}
+		status(200, itemsBody)(w, r)
+	}
+	server, _ := sequenceServer(t, unconditional, unconditional)
+
+	cache := NewCache(t.TempDir())
+	source := Source{Name: "Uncached", URL: server.URL, Response: source1.Response}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := source.GetItems(Options{Cache: cache}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+}
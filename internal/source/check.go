@@ -0,0 +1,111 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+)
+
+// MARK: Private helper methods
+// checkURL returns an error if the source's URL isn't a valid web URL
+func (source Source) checkURL() error {
+	u, err := url.Parse(source.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL `%s`: %w", source.URL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL `%s`: expected an `http` or `https` URL", source.URL)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL `%s`: no host", source.URL)
+	}
+
+	return nil
+}
+
+// MARK: Public methods
+// Check returns every problem of the sources, by their index: missing or duplicate names, invalid URLs,
+// tags that would make every task of their projects belong to them, settings that stop them from syncing and
+// the problems of their own projects.
+func Check(sources []Source) []diag.Problem {
+	var problems []diag.Problem
+	add := func(i int, severity diag.Severity, format string, args ...interface{}) {
+		problems = append(problems, diag.Problem{Index: i, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := map[string]int{}
+	for i, source := range sources {
+		if source.Name == "" {
+			add(i, diag.SeverityError, "source without a `Name`")
+		} else if first, ok := names[source.Name]; ok {
+			// The state of each source is kept by its name, so two sources would overwrite each other's
+			add(i, diag.SeverityError, "source %s is also configured as source %d, names must be unique", source.Name, first+1)
+		} else {
+			names[source.Name] = i
+		}
+
+		if err := source.checkURL(); err != nil {
+			add(i, diag.SeverityError, "source %s has an %s", source.Name, err)
+		}
+
+		// Without tags, the tasks of a source can only be found by searching every task of its projects
+		if len(source.Tags) == 0 {
+			add(i, diag.SeverityWarning, "source %s has no `Tags`, so every task in its projects is searched for its tasks", source.Name)
+		}
+
+		for _, tag := range source.Tags {
+			if strings.TrimSpace(tag) == "" {
+				add(i, diag.SeverityError, "source %s has an empty tag, which would make its tasks match every task", source.Name)
+				break
+			}
+		}
+
+		if _, err := source.MissingPolicy(); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		reasons := make([]string, 0, len(source.CloseActions))
+		for reason := range source.CloseActions {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		for _, reason := range reasons {
+			if _, err := source.GetCloseAction(reason); err != nil {
+				add(i, diag.SeverityError, "source %s: %s", source.Name, err)
+			}
+		}
+
+		if err := source.ValidateWriteback(); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		if err := source.ValidateOwnership(); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		if err := source.ValidateWebhook(); err != nil {
+			add(i, diag.SeverityError, "%s", err)
+		}
+
+		if err := source.Unmatched.Validate(); err != nil {
+			add(i, diag.SeverityError, "source %s: %s", source.Name, err)
+		}
+
+		if err := source.Schedule.Validate(); err != nil {
+			add(i, diag.SeverityError, "source %s: %s", source.Name, err)
+		}
+
+		for _, problem := range project.Check(source.Projects) {
+			add(i, problem.Severity, "source %s: %s", source.Name, problem.Message)
+		}
+	}
+
+	return problems
+}
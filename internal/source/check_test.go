@@ -0,0 +1,48 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/schedule"
+)
+
+// MARK: Check tests
+// Tests that missing and duplicate names, invalid URLs, missing or empty tags, invalid settings, projects, schedules and webhooks are reported by their index
+func TestCheck(t *testing.T) {
+	sources := []Source{
+		{Name: "GitHub", URL: "https://api.github.com/issues", Tags: []string{"github"}},
+		{Name: "GitHub", URL: "https://api.github.com/notifications", Tags: []string{"github"}},
+		{URL: "api.app.shortcut.com/stories", Tags: []string{"shortcut"}},
+		{Name: "Jira", URL: "https://jira.example.com", Tags: []string{}},
+		{Name: "Linear", URL: "https://api.linear.app", Tags: []string{"linear", " "}},
+		{Name: "GitLab", URL: "https://gitlab.com/api", Tags: []string{"gitlab"}, Missing: "forget", CloseActions: map[string]CloseAction{"b": "archive", "a": "drop"}},
+		{Name: "Trello", URL: "https://api.trello.com", Tags: []string{"trello"}, Unmatched: project.Fallback{Project: "Triage", Skip: true}},
+		{Name: "Asana", URL: "https://app.asana.com/api", Tags: []string{"asana"}, Projects: []project.Project{{URL: "https://app.asana.com"}}},
+		{Name: "Todoist", URL: "https://api.todoist.com", Tags: []string{"todoist"}, Schedule: schedule.Schedule{Cron: "every hour"}},
+		{Name: "Bitbucket", URL: "https://api.bitbucket.org", Tags: []string{"bitbucket"}, Webhook: &Webhook{Provider: ProviderGitHub}},
+		{Name: "Azure", URL: "https://dev.azure.com"},
+	}
+
+	expected := []diag.Problem{
+		{Index: 1, Severity: diag.SeverityError, Message: "source GitHub is also configured as source 1, names must be unique"},
+		{Index: 2, Severity: diag.SeverityError, Message: "source without a `Name`"},
+		{Index: 2, Severity: diag.SeverityError, Message: "source  has an invalid URL `api.app.shortcut.com/stories`: expected an `http` or `https` URL"},
+		{Index: 3, Severity: diag.SeverityWarning, Message: "source Jira has no `Tags`, so every task in its projects is searched for its tasks"},
+		{Index: 4, Severity: diag.SeverityError, Message: "source Linear has an empty tag, which would make its tasks match every task"},
+		{Index: 5, Severity: diag.SeverityError, Message: "unknown missing policy `forget` for GitLab"},
+		{Index: 5, Severity: diag.SeverityError, Message: "source GitLab: unknown close action `archive` for reason `b`"},
+		{Index: 6, Severity: diag.SeverityError, Message: "source Trello: a fallback can only set one of `Project`, `Inbox` or `Skip`"},
+		{Index: 7, Severity: diag.SeverityError, Message: "source Asana: invalid project https://app.asana.com: either `OFName` or `NameTemplate` is required"},
+		{Index: 8, Severity: diag.SeverityError, Message: "source Todoist: invalid cron expression `every hour`, expected five fields: minute hour day-of-month month day-of-week"},
+		{Index: 9, Severity: diag.SeverityError, Message: "invalid webhook for Bitbucket: `Secret` is required so that only github can trigger syncs"},
+		{Index: 10, Severity: diag.SeverityWarning, Message: "source Azure has no `Tags`, so every task in its projects is searched for its tasks"},
+	}
+
+	problems := Check(sources)
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Unexpected problems:\n%+v\nexpected:\n%+v", problems, expected)
+	}
+}
@@ -0,0 +1,194 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// Children maps a collection of child records of an item, like GitHub sub-issues, Jira sub-tasks or
+// Shortcut story tasks, to subtasks of the item's task
+type Children struct {
+	// The field holding the children. Nested fields are separated by dots, e.g. `fields.subtasks`
+	Field string `json:"Field"`
+	// Parse the field as a Markdown task list (`- [ ] ...`) instead of an array of records, e.g. the `body`
+	// of a GitHub issue
+	Checklist bool `json:"Checklist"`
+	// The field of a child holding its title, e.g. `description`
+	Title string `json:"Title"`
+	// The field of a child holding its stable ID, e.g. `id`. When empty, children are identified by their title
+	ID string `json:"ID"`
+	// The field of a child linking to it. When empty, the URL of the item with the child's ID as a fragment is used
+	URL string `json:"URL"`
+	// The field of a child that says whether it is done, in the same format as the item's state
+	State State `json:"State"`
+}
+
+// checklistItem matches the items of a Markdown task list
+var checklistItem = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.+?)\s*$`)
+
+// MARK: Private helper methods
+// lookup returns the value of the field in the record. Nested fields are separated by dots.
+func lookup(record map[string]interface{}, field string) interface{} {
+	if value, ok := record[field]; ok {
+		return value
+	}
+
+	key, rest, nested := strings.Cut(field, ".")
+	if !nested {
+		return nil
+	}
+
+	child, ok := record[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return lookup(child, rest)
+}
+
+// childID returns the stable ID of a child, falling back to a short hash of the key identifying it
+func childID(value interface{}, key string) string {
+	switch v := value.(type) {
+	case nil:
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])[:7]
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// records returns the child records of the item's fields. Checklist items are turned into records with
+// a `title` and a `done` field.
+func (c Children) records(fields map[string]interface{}) ([]map[string]interface{}, error) {
+	value := lookup(fields, c.Field)
+	if value == nil {
+		return nil, nil
+	}
+
+	if c.Checklist {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("`%s` is not a string", c.Field)
+		}
+
+		var records []map[string]interface{}
+		for _, line := range strings.Split(text, "\n") {
+			m := checklistItem.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			records = append(records, map[string]interface{}{
+				"title": m[2],
+				"done":  m[1] != " ",
+			})
+		}
+
+		return records, nil
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("`%s` is not an array", c.Field)
+	}
+
+	records := make([]map[string]interface{}, 0, len(values))
+	for i, v := range values {
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("child %d of `%s` is not an object", i, c.Field)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// parse turns the child records of the parent into new OmniFocus tasks under the parent's task. Children
+// of a closed parent are closed with it.
+func (c Children) parse(parent omnifocus.NewOmniFocusItem) ([]omnifocus.NewOmniFocusItem, error) {
+	records, err := c.records(parent.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	titleField, idField, state := c.Title, c.ID, c.State
+	if c.Checklist {
+		titleField, idField, state = "title", "", State{Field: "done", Closed: []string{"true"}}
+	}
+
+	// The number of the parent prefixes the IDs of its children, so they're unique across the source
+	number := strings.TrimPrefix(strings.SplitN(parent.Name, "]", 2)[0], "[")
+
+	// seen counts the children identified by each title so far
+	seen := map[string]int{}
+
+	items := make([]omnifocus.NewOmniFocusItem, 0, len(records))
+	for i, record := range records {
+		title, ok := lookup(record, titleField).(string)
+		if !ok {
+			return nil, fmt.Errorf("child %d has no string `%s` field", i, titleField)
+		}
+
+		var value interface{}
+		if idField != "" {
+			value = lookup(record, idField)
+		}
+
+		// Children with the same title are told apart by how many came before them, so the first keeps its ID
+		key := title
+		if value == nil {
+			if n := seen[title]; n > 0 {
+				key = fmt.Sprintf("%s\n%d", title, n)
+			}
+			seen[title]++
+		}
+		id := childID(value, key)
+
+		note := parent.Note + "#" + id
+		if c.URL != "" {
+			if url, ok := lookup(record, c.URL).(string); ok && url != "" {
+				note = url
+			}
+		}
+
+		closed, err := state.isClosed(record)
+		if err != nil {
+			return nil, fmt.Errorf("child %d has an %s", i, err)
+		}
+
+		items = append(items, omnifocus.NewOmniFocusItem{
+			Name:       fmt.Sprintf("[%s.%s] %s", number, id, title),
+			Tags:       parent.Tags,
+			Note:       note,
+			ParentNote: parent.Note,
+			Closed:     closed || parent.Closed,
+			Fields:     record,
+		})
+	}
+
+	return items, nil
+}
+
+// withChildren returns the item followed by its children, if the source maps any
+func (source Source) withChildren(item omnifocus.NewOmniFocusItem) ([]omnifocus.NewOmniFocusItem, error) {
+	if source.Response.Children == nil {
+		return []omnifocus.NewOmniFocusItem{item}, nil
+	}
+
+	children, err := source.Response.Children.parse(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the children of %s: %w", item.Name, err)
+	}
+
+	return append([]omnifocus.NewOmniFocusItem{item}, children...), nil
+}
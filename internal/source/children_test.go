@@ -0,0 +1,122 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// MARK: Children tests
+// Tests that a Markdown task list becomes subtasks identified by their title
+func TestChildrenChecklist(t *testing.T) {
+	source := Source{
+		Response: Response{
+			Title:    "title",
+			URL:      "html_url",
+			Number:   "number",
+			Children: &Children{Field: "body", Checklist: true},
+		},
+		Tags: []string{"github"},
+	}
+
+	items, err := source.parseResponse([]byte(`[{
+		"title": "Release",
+		"html_url": "https://github.com/owner/repo/issues/7",
+		"number": 7,
+		"body": "Steps:\n- [ ] Tag the release\n  - [x] Write the changelog\n* not a task"
+	}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(items) != 3 {
+		t.Fatalf("Expected the item and 2 subtasks, was: %v", items)
+	}
+
+	first, second := items[1], items[2]
+	if first.ParentNote != "https://github.com/owner/repo/issues/7" || first.Closed || !second.Closed {
+		t.Fatalf("Unexpected subtasks: %+v, %+v", first, second)
+	}
+
+	if !reflect.DeepEqual(first.Tags, []string{"github"}) {
+		t.Fatalf("Expected subtasks to have the tags of the source, was: %v", first.Tags)
+	}
+
+	// Children without an ID are identified by their title, so the same title always gives the same task
+	again, _ := source.Response.Children.parse(items[0])
+	if again[0].Name != first.Name || first.Name == second.Name {
+		t.Fatalf("Unexpected names: %s, %s, %s", first.Name, again[0].Name, second.Name)
+	}
+
+	if first.Note != "https://github.com/owner/repo/issues/7#"+first.Name[3:10] {
+		t.Fatalf("Unexpected note: %s", first.Note)
+	}
+
+	// Items with the same title get their own tasks, the first keeping the ID of a lone one
+	items[0].Fields["body"] = "- [ ] Tag the release\n- [ ] Tag the release"
+	duplicates, _ := source.Response.Children.parse(items[0])
+	if len(duplicates) != 2 || duplicates[0].Note != first.Note || duplicates[1].Note == first.Note {
+		t.Fatalf("Unexpected subtasks: %+v", duplicates)
+	}
+}
+
+// Tests that an array of child records becomes subtasks with their own IDs, URLs and states
+func TestChildrenRecords(t *testing.T) {
+	children := Children{
+		Field: "fields.subtasks",
+		Title: "fields.summary",
+		ID:    "id",
+		URL:   "self",
+		State: State{Field: "fields.status.name", Closed: []string{"Done"}},
+	}
+
+	parent := omnifocus.NewOmniFocusItem{
+		Name: "[12] Migrate",
+		Note: "https://jira.example.com/browse/OPS-12",
+		Fields: map[string]interface{}{
+			"fields": map[string]interface{}{
+				"subtasks": []interface{}{
+					map[string]interface{}{
+						"id":     float64(10001),
+						"self":   "https://jira.example.com/browse/OPS-13",
+						"fields": map[string]interface{}{"summary": "Copy data", "status": map[string]interface{}{"name": "Done"}},
+					},
+					map[string]interface{}{
+						"id":     float64(10002),
+						"fields": map[string]interface{}{"summary": "Switch over", "status": map[string]interface{}{"name": "To Do"}},
+					},
+				},
+			},
+		},
+	}
+
+	items, err := children.parse(parent)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 subtasks, was: %v", items)
+	}
+
+	if items[0].Name != "[12.10001] Copy data" || items[0].Note != "https://jira.example.com/browse/OPS-13" || !items[0].Closed {
+		t.Fatalf("Unexpected subtask: %+v", items[0])
+	}
+
+	if items[1].Name != "[12.10002] Switch over" || items[1].Note != "https://jira.example.com/browse/OPS-12#10002" || items[1].Closed {
+		t.Fatalf("Unexpected subtask: %+v", items[1])
+	}
+
+	// Closing the parent closes every child with it
+	parent.Closed = true
+	items, _ = children.parse(parent)
+	if !items[1].Closed {
+		t.Fatalf("Expected the children of a closed parent to be closed: %+v", items[1])
+	}
+
+	_, err = Children{Field: "fields", Title: "summary"}.parse(parent)
+	if err == nil || err.Error() != "`fields` is not an array" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultMaxAttempts is the number of attempts made when a source doesn't configure its own
+	defaultMaxAttempts = 4
+	// defaultBaseDelay is the delay before the first retry when a source doesn't configure its own
+	defaultBaseDelay = 500 * time.Millisecond
+	// maxBackoff caps the exponential backoff between two attempts
+	maxBackoff = 30 * time.Second
+	// maxRateLimitWait is the longest we wait for a rate limit to reset before giving up on a source
+	maxRateLimitWait = 2 * time.Minute
+	// maxErrorBody is the number of bytes of a failed response body included in errors
+	maxErrorBody = 200
+)
+
+// sleep pauses between attempts. It is replaced in tests so retries don't slow them down.
+var sleep = time.Sleep
+
+// now returns the current time. It is replaced in tests to make rate limit resets deterministic.
+var now = time.Now
+
+// Retry configures how failed requests to a source are retried
+type Retry struct {
+	// The maximum number of attempts, including the first one. Defaults to 4
+	MaxAttempts int `json:"MaxAttempts"`
+	// The delay in milliseconds before the first retry, doubled for every following retry. Defaults to 500
+	BaseDelayMS int `json:"BaseDelayMS"`
+}
+
+// StatusError is returned when a source responds with a non 2xx status code
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status %s", e.Status)
+	}
+
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// MARK: Private helper methods
+// maxAttempts returns the number of attempts to make for the source
+func (r Retry) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+
+	return defaultMaxAttempts
+}
+
+// backoff returns the jittered delay to wait after the given failed attempt
+func (r Retry) backoff(attempt int) time.Duration {
+	delay := defaultBaseDelay
+	if r.BaseDelayMS > 0 {
+		delay = time.Duration(r.BaseDelayMS) * time.Millisecond
+	}
+
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	// Wait somewhere between half and the full delay so that sources failing together don't retry in lockstep
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter returns how long the server asked us to wait, using either the `Retry-After` header or
+// GitHub's `X-RateLimit-Remaining` and `X-RateLimit-Reset` headers. The second return value is false
+// when the response carries no such instruction.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if value := res.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(value); err == nil {
+			return clampWait(date.Sub(now())), true
+		}
+	}
+
+	if res.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return clampWait(time.Unix(reset, 0).Sub(now())), true
+		}
+	}
+
+	return 0, false
+}
+
+// clampWait makes sure a wait computed from a server timestamp is never negative
+func clampWait(wait time.Duration) time.Duration {
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+// retryDelay decides whether a failed response should be retried and how long to wait before doing so
+func (r Retry) retryDelay(res *http.Response, body []byte, attempt int) (time.Duration, error) {
+	statusErr := &StatusError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       truncate(body, maxErrorBody),
+	}
+
+	wait, limited := retryAfter(res)
+	switch {
+	case limited && (res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500):
+		if wait > maxRateLimitWait {
+			return 0, fmt.Errorf("rate limited until %s: %w", now().Add(wait).Format(time.RFC3339), statusErr)
+		}
+
+		return wait, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return r.backoff(attempt), nil
+	default:
+		return 0, statusErr
+	}
+}
+
+// fetch sends a GET request to the given url, with the given extra headers, and returns the first
+// successful or `304 Not Modified` response along with its body. Network errors and 5xx responses are
+// retried with jittered exponential backoff and rate limited responses are retried once the limit resets.
+func (source Source) fetch(client *http.Client, url string, header http.Header) (*http.Response, []byte, error) {
+	return source.send(client, http.MethodGet, url, header, nil)
+}
+
+// send sends a request with the given method and payload to the given url, retrying it like fetch
+func (source Source) send(client *http.Client, method, url string, header http.Header, payload []byte) (*http.Response, []byte, error) {
+	var lastErr error
+
+	attempts := source.Retry.maxAttempts()
+	for attempt := 1; attempt <= attempts; attempt++ {
+		req, err := source.newRequest(method, url, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to send request: %w", err)
+			source.wait(attempt, attempts, source.Retry.backoff(attempt), lastErr)
+			continue
+		}
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("failed to read response body: %w", err)
+			source.wait(attempt, attempts, source.Retry.backoff(attempt), lastErr)
+			continue
+		}
+
+		if res.StatusCode >= 200 && res.StatusCode < 300 || res.StatusCode == http.StatusNotModified {
+			if remaining := res.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+				if n, err := strconv.Atoi(remaining); err == nil && n < 10 {
+					log.Printf("[source] %s has %d requests left before it is rate limited", source.Name, n)
+				}
+			}
+
+			return res, body, nil
+		}
+
+		delay, err := source.Retry.retryDelay(res, body, attempt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lastErr = &StatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Body:       truncate(body, maxErrorBody),
+		}
+		source.wait(attempt, attempts, delay, lastErr)
+	}
+
+	return nil, nil, fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+}
+
+// wait logs the failed attempt and sleeps for the given delay, unless it was the last attempt
+func (source Source) wait(attempt, attempts int, delay time.Duration, err error) {
+	if attempt >= attempts {
+		return
+	}
+
+	log.Printf("[source] Attempt %d/%d for %s failed (%s), retrying in %s", attempt, attempts, source.Name, err, delay.Round(time.Millisecond))
+	sleep(delay)
+}
+
+// truncate returns the body as a string, cut down to at most n bytes
+func truncate(body []byte, n int) string {
+	if len(body) <= n {
+		return string(body)
+	}
+
+	return string(body[:n]) + "..."
+}
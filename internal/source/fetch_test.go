@@ -0,0 +1,198 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MARK: SETUP
+// stubSleep replaces `sleep` for the duration of a test and records every requested delay
+func stubSleep(t *testing.T) *[]time.Duration {
+	var delays []time.Duration
+	sleep = func(d time.Duration) {
+		delays = append(delays, d)
+	}
+	t.Cleanup(func() {
+		sleep = time.Sleep
+	})
+
+	return &delays
+}
+
+// sequenceServer returns a server that answers each request with the next handler in the list
+func sequenceServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls >= len(handlers) {
+			t.Fatalf("Unexpected request %d", calls+1)
+		}
+		handlers[calls](w, r)
+		calls++
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func status(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		fmt.Fprint(w, body)
+	}
+}
+
+const itemsBody = `[{"Title": "title", "url": "https://example.com/1", "number": 1}]`
+
+// MARK: GetItems tests
+// Tests that 5xx responses are retried until the source answers
+func TestGetItemsRetriesServerErrors(t *testing.T) {
+	delays := stubSleep(t)
+	server, calls := sequenceServer(t, status(502, "bad gateway"), status(503, "unavailable"), status(200, itemsBody))
+
+	source := Source{Name: "Flaky", URL: server.URL, Response: source1.Response}
+	items, _, err := source.GetItems(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(items) != 1 || items[0].Name != "[1] title" {
+		t.Fatalf("Unexpected items: %v", items)
+	}
+
+	if *calls != 3 || len(*delays) != 2 {
+		t.Fatalf("Expected 3 calls and 2 delays, was %d and %d", *calls, len(*delays))
+	}
+
+	if (*delays)[0] < 250*time.Millisecond || (*delays)[0] > 500*time.Millisecond {
+		t.Fatalf("Unexpected first backoff: %s", (*delays)[0])
+	}
+
+	if (*delays)[1] < 500*time.Millisecond || (*delays)[1] > time.Second {
+		t.Fatalf("Unexpected second backoff: %s", (*delays)[1])
+	}
+}
+
+// Tests that client errors are returned straight away with the source name and status
+func TestGetItemsDoesNotRetryClientErrors(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, status(401, `{"message": "Bad credentials"}`))
+
+	source := Source{Name: "GitHub", URL: server.URL, Response: source1.Response}
+	_, _, err := source.GetItems(Options{})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 401 {
+		t.Fatalf("Expected a 401 status error, was: %v", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), "failed to get items from GitHub: unexpected status 401") || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if *calls != 1 {
+		t.Fatalf("Expected 1 call, was %d", *calls)
+	}
+}
+
+// Tests that the source gives up after the configured number of attempts
+func TestGetItemsGivesUp(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, status(500, ""), status(500, ""))
+
+	source := Source{Name: "Down", URL: server.URL, Response: source1.Response, Retry: Retry{MaxAttempts: 2}}
+	_, _, err := source.GetItems(Options{})
+	if err == nil || !strings.Contains(err.Error(), "giving up after 2 attempts") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if *calls != 2 {
+		t.Fatalf("Expected 2 calls, was %d", *calls)
+	}
+}
+
+// Tests that `Retry-After` is respected
+func TestGetItemsRespectsRetryAfter(t *testing.T) {
+	delays := stubSleep(t)
+	server, _ := sequenceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}, status(200, itemsBody))
+
+	source := Source{Name: "Limited", URL: server.URL, Response: source1.Response}
+	if _, _, err := source.GetItems(Options{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(*delays) != 1 || (*delays)[0] != 7*time.Second {
+		t.Fatalf("Unexpected delays: %v", *delays)
+	}
+}
+
+// Tests that GitHub's rate limit headers are respected
+func TestGetItemsRespectsRateLimitReset(t *testing.T) {
+	delays := stubSleep(t)
+	fixed := time.Unix(1700000000, 0)
+	now = func() time.Time { return fixed }
+	t.Cleanup(func() { now = time.Now })
+
+	rateLimited := func(reset time.Duration) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(fixed.Add(reset).Unix()))
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}
+
+	server, _ := sequenceServer(t, rateLimited(30*time.Second), status(200, itemsBody))
+	source := Source{Name: "GitHub", URL: server.URL, Response: source1.Response}
+	if _, _, err := source.GetItems(Options{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(*delays) != 1 || (*delays)[0] != 30*time.Second {
+		t.Fatalf("Unexpected delays: %v", *delays)
+	}
+
+	// A reset too far in the future fails the source instead of blocking the run
+	server, calls := sequenceServer(t, rateLimited(time.Hour))
+	source.URL = server.URL
+	_, _, err := source.GetItems(Options{})
+	if err == nil || !strings.Contains(err.Error(), "rate limited until") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if *calls != 1 {
+		t.Fatalf("Expected 1 call, was %d", *calls)
+	}
+}
+
+// Tests that a plain 403 isn't mistaken for a rate limit
+func TestGetItemsForbidden(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, status(403, "forbidden"))
+
+	source := Source{Name: "Private", URL: server.URL, Response: source1.Response}
+	_, _, err := source.GetItems(Options{})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 403 || *calls != 1 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Tests that a response missing a mapped field reports which field is missing
+func TestGetItemsMissingField(t *testing.T) {
+	stubSleep(t)
+	server, _ := sequenceServer(t, status(200, `[{"Title": "title", "number": 1}]`))
+
+	source := Source{Name: "Partial", URL: server.URL, Response: source1.Response}
+	_, _, err := source.GetItems(Options{})
+	if err == nil || err.Error() != "failed to get items from Partial: item 0 has no string `url` field" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
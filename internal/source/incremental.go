@@ -0,0 +1,239 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// Incremental configures a source to only request the items updated since its last run. The open items
+// are kept in a local snapshot between runs, and items the source reports closed are completed.
+type Incremental struct {
+	// The query parameter that carries the time of the last run, e.g. `since`
+	Param string `json:"Param"`
+	// The Go time layout of the parameter. Defaults to RFC 3339
+	Format string `json:"Format"`
+	// Extra query parameters for incremental requests so that closed items are returned too, e.g. `state=all`
+	Query string `json:"Query"`
+	// The RFC 3339 field holding when an item was last updated, used to advance the watermark with the
+	// source's own clock. When empty, the time of the request is used instead
+	UpdatedField string `json:"UpdatedField"`
+}
+
+// snapshot is the state an incremental source keeps between runs
+type snapshot struct {
+	// Watermark is the time up to which all updates have been fetched
+	Watermark time.Time `json:"watermark"`
+	// Records are the raw records of the open items of the source, keyed by their URL. They are parsed
+	// again on every run so that changes to the response mapping apply to them too
+	Records map[string]map[string]interface{} `json:"records"`
+}
+
+// Snapshot is the state of an incremental source after fetching its items. It is only saved once the changes
+// of the run were applied, so the items closed since the last run are fetched again until their tasks are
+// closed, even when a safety limit held them back or the run failed.
+type Snapshot struct {
+	source Source
+	dir    string
+	snap   snapshot
+}
+
+// MARK: Private helper methods
+// snapshotPath returns the file the snapshot of the source is stored in
+func (source Source) snapshotPath(dir string) string {
+	sum := sha256.Sum256([]byte(source.Name + "\n" + source.URL))
+	return path.Join(dir, "snapshots", hex.EncodeToString(sum[:])+".json")
+}
+
+// loadSnapshot returns the stored snapshot of the source, or an empty one on the first run
+func (source Source) loadSnapshot(dir string) (snapshot, error) {
+	snap := snapshot{Records: map[string]map[string]interface{}{}}
+
+	bytes, err := os.ReadFile(source.snapshotPath(dir))
+	if os.IsNotExist(err) {
+		return snap, nil
+	} else if err != nil {
+		return snap, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	err = json.Unmarshal(bytes, &snap)
+	if err != nil {
+		return snap, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if snap.Records == nil {
+		snap.Records = map[string]map[string]interface{}{}
+	}
+
+	return snap, nil
+}
+
+// saveSnapshot stores the snapshot of the source
+func (source Source) saveSnapshot(dir string, snap snapshot) error {
+	bytes, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	p := source.snapshotPath(dir)
+	err = os.MkdirAll(path.Dir(p), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a half written snapshot behind
+	err = os.WriteFile(p+".tmp", bytes, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+// incrementalParams returns the query parameters that request the items updated since the watermark
+func (inc Incremental) incrementalParams(watermark time.Time) (url.Values, error) {
+	params, err := url.ParseQuery(inc.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid incremental query: %w", err)
+	}
+
+	format := inc.Format
+	if format == "" {
+		format = time.RFC3339
+	}
+
+	params.Set(inc.Param, watermark.UTC().Format(format))
+	return params, nil
+}
+
+// latestUpdate returns the latest update time of the records, or the fallback if none of them has one
+func (inc Incremental) latestUpdate(records []map[string]interface{}, fallback time.Time) time.Time {
+	if inc.UpdatedField == "" {
+		return fallback
+	}
+
+	latest := time.Time{}
+	for _, record := range records {
+		value, ok := record[inc.UpdatedField].(string)
+		if !ok {
+			continue
+		}
+
+		updated, err := time.Parse(time.RFC3339, value)
+		if err == nil && updated.After(latest) {
+			latest = updated
+		}
+	}
+
+	if latest.IsZero() {
+		return fallback
+	}
+
+	return latest
+}
+
+// getIncremental fetches the items updated since the last run and merges them into the stored snapshot,
+// returning the snapshot to save once they were synced. The returned items are all open items of the
+// snapshot, plus the items closed since the last run.
+func (source Source) getIncremental(client *http.Client, opts Options) ([]omnifocus.NewOmniFocusItem, *Snapshot, error) {
+	snap, err := source.loadSnapshot(opts.StateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	full := opts.Full || snap.Watermark.IsZero()
+
+	var extra url.Values
+	if !full {
+		extra, err = source.Incremental.incrementalParams(snap.Watermark)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	start := now()
+	reqURL := source.createURL(extra)
+	_, body, err := source.fetch(client, reqURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := source.decodeRecords(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if full {
+		log.Printf("[source] Fetched all items of %s", source.Name)
+		snap.Records = map[string]map[string]interface{}{}
+	} else {
+		log.Printf("[source] Fetched %d items of %s updated since %s", len(records), source.Name, snap.Watermark.Format(time.RFC3339))
+	}
+
+	var closed []omnifocus.NewOmniFocusItem
+	for i, record := range records {
+		item, err := source.parseRecord(i, record)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if item.Closed {
+			delete(snap.Records, item.Note)
+
+			withChildren, err := source.withChildren(item)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			closed = append(closed, withChildren...)
+		} else {
+			snap.Records[item.Note] = record
+		}
+	}
+
+	snap.Watermark = source.Incremental.latestUpdate(records, start)
+
+	keys := make([]string, 0, len(snap.Records))
+	for key := range snap.Records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]omnifocus.NewOmniFocusItem, 0, len(keys)+len(closed))
+	for i, key := range keys {
+		item, err := source.parseRecord(i, snap.Records[key])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+
+		withChildren, err := source.withChildren(item)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+
+		items = append(items, withChildren...)
+	}
+
+	return append(items, closed...), &Snapshot{source: source, dir: opts.StateDir, snap: snap}, nil
+}
+
+// MARK: Public methods
+// Save stores the snapshot, moving the watermark of its source forward. A nil snapshot, the one of a source
+// that isn't incremental, is never saved.
+func (s *Snapshot) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	return s.source.saveSnapshot(s.dir, s.snap)
+}
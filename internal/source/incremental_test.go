@@ -0,0 +1,139 @@
+package source
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// MARK: Incremental tests
+// Tests that the second run only requests updated items and merges them into the snapshot
+func TestGetItemsIncremental(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" {
+			t.Fatalf("Unexpected query on the first run: %s", r.URL.RawQuery)
+		}
+		status(200, `[
+			{"Title": "one", "url": "https://example.com/1", "number": 1, "state": "open", "updated_at": "2023-11-14T10:00:00Z"},
+			{"Title": "two", "url": "https://example.com/2", "number": 2, "state": "open", "updated_at": "2023-11-14T11:00:00Z"}
+		]`)(w, r)
+	}, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("since") != "2023-11-14T11:00:00Z" || query.Get("state") != "all" {
+			t.Fatalf("Unexpected query on the second run: %s", r.URL.RawQuery)
+		}
+		status(200, `[
+			{"Title": "two", "url": "https://example.com/2", "number": 2, "state": "closed", "updated_at": "2023-11-14T12:00:00Z"},
+			{"Title": "three", "url": "https://example.com/3", "number": 3, "state": "open", "updated_at": "2023-11-14T12:30:00Z"}
+		]`)(w, r)
+	})
+
+	response := source1.Response
+	response.State = State{Field: "state", Closed: []string{"closed"}}
+	source := Source{
+		Name:     "Incremental",
+		URL:      server.URL,
+		Response: response,
+		Incremental: &Incremental{
+			Param:        "since",
+			Query:        "state=all",
+			UpdatedField: "updated_at",
+		},
+	}
+
+	opts := Options{StateDir: t.TempDir()}
+	items, snap, err := source.GetItems(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items on the first run, was: %v", items)
+	}
+
+	if err := snap.Save(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	items, snap, err = source.GetItems(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := snap.Save(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]bool{
+		"[1] one":   false,
+		"[3] three": false,
+		"[2] two":   true,
+	}
+
+	if len(items) != len(expected) || *calls != 2 {
+		t.Fatalf("Unexpected items on the second run: %v", items)
+	}
+
+	for _, item := range items {
+		closed, ok := expected[item.Name]
+		if !ok || closed != item.Closed {
+			t.Fatalf("Unexpected item: %s (closed: %t)", item.Name, item.Closed)
+		}
+	}
+
+	stored, err := source.loadSnapshot(opts.StateDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !stored.Watermark.Equal(time.Date(2023, 11, 14, 12, 30, 0, 0, time.UTC)) || len(stored.Records) != 2 {
+		t.Fatalf("Unexpected snapshot: %v", stored)
+	}
+}
+
+// Tests that a failed request leaves the snapshot untouched
+func TestGetItemsIncrementalFailure(t *testing.T) {
+	stubSleep(t)
+	server, _ := sequenceServer(t, status(401, "unauthorized"))
+
+	source := Source{Name: "Incremental", URL: server.URL, Response: source1.Response, Incremental: &Incremental{Param: "since"}}
+	opts := Options{StateDir: t.TempDir()}
+	if _, _, err := source.GetItems(opts); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	snap, err := source.loadSnapshot(opts.StateDir)
+	if err != nil || !snap.Watermark.IsZero() || len(snap.Records) != 0 {
+		t.Fatalf("Unexpected snapshot: %v (%v)", snap, err)
+	}
+}
+
+// Tests that fetching leaves the stored snapshot untouched until it is saved, so items closed since the last
+// run are returned again when their tasks couldn't be closed
+func TestGetItemsIncrementalUnsaved(t *testing.T) {
+	stubSleep(t)
+	closed := `[{"Title": "one", "url": "https://example.com/1", "number": 1, "state": "closed", "updated_at": "2023-11-14T10:00:00Z"}]`
+	server, calls := sequenceServer(t, status(200, closed), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" {
+			t.Fatalf("Unexpected query after an unsaved run: %s", r.URL.RawQuery)
+		}
+		status(200, closed)(w, r)
+	})
+
+	response := source1.Response
+	response.State = State{Field: "state", Closed: []string{"closed"}}
+	source := Source{Name: "Incremental", URL: server.URL, Response: response, Incremental: &Incremental{Param: "since", UpdatedField: "updated_at"}}
+	opts := Options{StateDir: t.TempDir()}
+	for run := 1; run <= 2; run++ {
+		items, snap, err := source.GetItems(opts)
+		if err != nil || len(items) != 1 || !items[0].Closed || snap == nil {
+			t.Fatalf("Unexpected items of run %d: %v (%v)", run, items, err)
+		}
+	}
+
+	stored, err := source.loadSnapshot(opts.StateDir)
+	if err != nil || !stored.Watermark.IsZero() || len(stored.Records) != 0 || *calls != 2 {
+		t.Fatalf("Unexpected snapshot: %v (%v)", stored, err)
+	}
+}
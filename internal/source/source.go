@@ -1,6 +1,7 @@
 package source
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,9 +10,15 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+	"github.com/trevorpiltch/omnifocus-sync/internal/diag"
+	"github.com/trevorpiltch/omnifocus-sync/internal/project"
+	"github.com/trevorpiltch/omnifocus-sync/internal/schedule"
+	"github.com/trevorpiltch/omnifocus-sync/internal/state"
 )
 
 // Header represent a header that we want to attach in a HTTP request
@@ -30,6 +37,101 @@ type Response struct {
 	URL string `json:"URL"`
 	// The number of the item that
 	Number string `json:"Number"`
+	// The field that says whether the item is open or closed
+	State State `json:"State"`
+	// The field holding when the item is due, as RFC 3339 or a `2006-01-02` date. Leave empty for no due dates
+	Due string `json:"Due"`
+	// The collection of child records that become subtasks of the item. Leave empty for items without subtasks
+	Children *Children `json:"Children"`
+}
+
+// State maps the field of a response that says whether an item is open or closed
+type State struct {
+	// The field holding the state of the item, e.g. `state`. Leave empty if the source only returns open items
+	Field string `json:"Field"`
+	// The values of the field that mean the item is open, e.g. `open`. When empty, every value that isn't closed is open
+	Open []string `json:"Open"`
+	// The values of the field that mean the item is closed, e.g. `closed`. When empty, every value that isn't open is closed
+	Closed []string `json:"Closed"`
+	// The field holding why a closed item was closed, e.g. `state_reason`. Used to pick a `CloseActions` entry
+	Reason string `json:"Reason"`
+}
+
+// MissingPolicy says what happens to a task whose item is no longer returned by its source
+type MissingPolicy string
+
+const (
+	// MissingComplete marks the task complete
+	MissingComplete MissingPolicy = "complete"
+	// MissingDrop marks the task dropped
+	MissingDrop MissingPolicy = "drop"
+	// MissingTag adds the orphan tag to the task and leaves it open
+	MissingTag MissingPolicy = "tag"
+	// MissingIgnore leaves the task alone
+	MissingIgnore MissingPolicy = "ignore"
+	// MissingDelete deletes the task
+	MissingDelete MissingPolicy = "delete"
+)
+
+// CloseAction says what happens to a task when the source reports its item closed
+type CloseAction string
+
+const (
+	// CloseComplete marks the task complete
+	CloseComplete CloseAction = "complete"
+	// CloseDrop marks the task dropped
+	CloseDrop CloseAction = "drop"
+	// CloseDelete deletes the task
+	CloseDelete CloseAction = "delete"
+)
+
+// defaultOrphanTag is the tag added to orphaned tasks when a source doesn't configure its own
+const defaultOrphanTag = "orphaned"
+
+// isClosed returns whether the record is reported closed by the source
+func (state State) isClosed(record map[string]interface{}) (bool, error) {
+	if state.Field == "" {
+		return false, nil
+	}
+
+	value := fmt.Sprint(lookup(record, state.Field))
+	open := contains(state.Open, value)
+	closed := contains(state.Closed, value)
+
+	switch {
+	case open && !closed:
+		return false, nil
+	case closed && !open:
+		return true, nil
+	case len(state.Open) == 0 && len(state.Closed) > 0:
+		return false, nil
+	case len(state.Closed) == 0 && len(state.Open) > 0:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown `%s` value `%s`", state.Field, value)
+	}
+}
+
+// contains returns whether the value is one of the values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Safety limits how many tasks a single run may complete for a source. It guards against an expired
+// token or an error body making every synced task look like it was closed.
+type Safety struct {
+	// The maximum number of tasks that may be completed in one run, 0 for no limit. Overrides the global
+	// limit when set
+	MaxComplete *int `json:"MaxComplete"`
+	// The maximum percentage of current tasks that may be completed in one run, 0 for no limit. Overrides the
+	// global limit when set
+	MaxCompletePercent *float64 `json:"MaxCompletePercent"`
 }
 
 // Source represents a location where we are getting the OmniFocus items from
@@ -46,24 +148,82 @@ type Source struct {
 	Response Response `json:"Response"`
 	// The tags to add to OmniFocus items when they're added
 	Tags []string `json:"Tags"`
+	// How failed requests to the source are retried
+	Retry Retry `json:"Retry"`
+	// The limits on how many tasks may be completed in one run
+	Safety Safety `json:"Safety"`
+	// Only request the items updated since the last run. Leave empty to fetch all items every run
+	Incremental *Incremental `json:"Incremental"`
+	// What happens to tasks whose item is no longer returned: `complete`, `drop`, `tag` or `ignore`.
+	// Defaults to `complete`, or to `tag` when the response maps a state
+	Missing MissingPolicy `json:"Missing"`
+	// The tag added to tasks by the `tag` policy. Defaults to `orphaned`
+	OrphanTag string `json:"OrphanTag"`
+	// What happens to a task when its item is closed, keyed by the reason from `Response.State.Reason`:
+	// `complete`, `drop` or `delete`. Reasons that aren't listed complete the task
+	CloseActions map[string]CloseAction `json:"CloseActions"`
+	// Reopen completed or dropped tasks when their item is reopened, instead of adding a new task
+	Reopen bool `json:"Reopen"`
+	// Where items that don't match any project go. Overrides the global setting when set
+	Unmatched project.Fallback `json:"Unmatched"`
+	// Add every item of the source to the OmniFocus Inbox instead of routing it to a project
+	Inbox bool `json:"Inbox"`
+	// Move tasks the user moved to another project back to the project their item is routed to. By
+	// default moved tasks stay where they are
+	ForceProject bool `json:"ForceProject"`
+	// Close items upstream when their tasks are completed or dropped in OmniFocus. Leave empty for a one-way sync
+	Writeback *Writeback `json:"Writeback"`
+	// Who wins when a field of a task was changed both upstream and in OmniFocus, keyed by field: `name`,
+	// `note`, `tags` or `due`. Each is `upstream`, `local` or `append`, and defaults to `upstream`
+	Ownership map[string]delta.Ownership `json:"Ownership"`
+	// The projects that route the items of this source only. They are tried before the global projects and
+	// win ties with them
+	Projects []project.Project `json:"Projects"`
+	// When the source is synced by `omnisync daemon`. Overrides the global schedule when set
+	Schedule schedule.Schedule `json:"Schedule"`
+	// The webhooks the source sends to `omnisync daemon`, which sync the items they concern straight away.
+	// Leave empty to only poll the source
+	Webhook *Webhook `json:"Webhook"`
 }
 
 // MARK: Private helper methods
-// Creates the full URL from the source
-func (source Source) createURL() string {
+// Creates the full URL from the source, adding the given extra query parameters
+func (source Source) createURL(extra url.Values) string {
 	params := url.Values{}
 
-  if source.Queries != ""  {
-    params.Add("query", source.Queries)
-    return source.URL + "?" + params.Encode()
-  }
+	if source.Queries != "" {
+		params.Add("query", source.Queries)
+	}
+
+	for key, values := range extra {
+		params[key] = append(params[key], values...)
+	}
+
+	if len(params) == 0 {
+		return source.URL
+	}
+
+	separator := "?"
+	if strings.Contains(source.URL, "?") {
+		separator = "&"
+	}
 
-  return source.URL
+	return source.URL + separator + params.Encode()
 }
 
 // Creates the request from the source using the given url
 func (source Source) createRequest(url string) (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return source.newRequest(http.MethodGet, url, nil)
+}
+
+// Creates a request with the given method and body, carrying the headers of the source
+func (source Source) newRequest(method, url string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -75,8 +235,8 @@ func (source Source) createRequest(url string) (*http.Request, error) {
 	return req, nil
 }
 
-// parseResponse parses an array of bytes into an array of new OmniFocus tasks
-func (source Source) parseResponse(data []byte) ([]omnifocus.NewOmniFocusItem, error) {
+// decodeRecords parses an array of bytes into the raw records of the response
+func (source Source) decodeRecords(data []byte) ([]map[string]interface{}, error) {
 	if source.Response.DataField != "" {
 		var m map[string]interface{}
 		err := json.Unmarshal(data, &m)
@@ -90,29 +250,99 @@ func (source Source) parseResponse(data []byte) ([]omnifocus.NewOmniFocusItem, e
 		}
 	}
 
-	var responses []map[string]interface{}
+	var records []map[string]interface{}
 
-	err := json.Unmarshal(data, &responses)
+	err := json.Unmarshal(data, &records)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse sources: %s", err)
 	}
 
+	return records, nil
+}
+
+// parseRecord turns the i-th record of the response into a new OmniFocus task
+func (source Source) parseRecord(i int, record map[string]interface{}) (omnifocus.NewOmniFocusItem, error) {
+	number, ok := record[source.Response.Number].(float64)
+	if !ok {
+		return omnifocus.NewOmniFocusItem{}, fmt.Errorf("item %d has no numeric `%s` field", i, source.Response.Number)
+	}
+
+	title, ok := record[source.Response.Title].(string)
+	if !ok {
+		return omnifocus.NewOmniFocusItem{}, fmt.Errorf("item %d has no string `%s` field", i, source.Response.Title)
+	}
+
+	note, ok := record[source.Response.URL].(string)
+	if !ok {
+		return omnifocus.NewOmniFocusItem{}, fmt.Errorf("item %d has no string `%s` field", i, source.Response.URL)
+	}
+
+	closed, err := source.Response.State.isClosed(record)
+	if err != nil {
+		return omnifocus.NewOmniFocusItem{}, fmt.Errorf("item %d has an %s", i, err)
+	}
+
+	var due int64
+	if source.Response.Due != "" && record[source.Response.Due] != nil {
+		value, _ := record[source.Response.Due].(string)
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		}
+		if err != nil {
+			return omnifocus.NewOmniFocusItem{}, fmt.Errorf("item %d has an invalid `%s` date `%v`", i, source.Response.Due, record[source.Response.Due])
+		}
+
+		due = t.UnixMilli()
+	}
+
+	reason := ""
+	if closed && source.Response.State.Reason != "" && record[source.Response.State.Reason] != nil {
+		reason = fmt.Sprint(record[source.Response.State.Reason])
+	}
+
+	return omnifocus.NewOmniFocusItem{
+		Name:        fmt.Sprintf("[%d] %s", int(number), title),
+		Tags:        source.Tags,
+		Note:        note,
+		DueDateMS:   due,
+		Closed:      closed,
+		CloseReason: reason,
+		Fields:      record,
+	}, nil
+}
+
+// parseResponse parses an array of bytes into an array of new OmniFocus tasks
+func (source Source) parseResponse(data []byte) ([]omnifocus.NewOmniFocusItem, error) {
+	records, err := source.decodeRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.ParseRecords(records)
+}
+
+// MARK: Public methods
+// ParseRecords turns the raw records of the source into new OmniFocus tasks, followed by the subtasks of each
+func (source Source) ParseRecords(records []map[string]interface{}) ([]omnifocus.NewOmniFocusItem, error) {
 	var items []omnifocus.NewOmniFocusItem
-	for _, response := range responses {
-		name := fmt.Sprintf("[%d] %s", int(response[source.Response.Number].(float64)), response[source.Response.Title].(string))
-		item := omnifocus.NewOmniFocusItem{
-			Name: name,
-			Tags: source.Tags,
-			Note: response[source.Response.URL].(string),
+	for i, record := range records {
+		item, err := source.parseRecord(i, record)
+		if err != nil {
+			return nil, err
+		}
+
+		withChildren, err := source.withChildren(item)
+		if err != nil {
+			return nil, err
 		}
 
-		items = append(items, item)
+		items = append(items, withChildren...)
 	}
 
 	return items, nil
 }
 
-// MARK: Public methods
 // loadSources parses the `sources.json` file at the given path and returns an array of Source objects
 func LoadSources(Path string) ([]Source, error) {
 	log.Printf("[source] Getting sources from: %s\n", Path)
@@ -127,41 +357,211 @@ func LoadSources(Path string) ([]Source, error) {
 
 	var sources []Source
 
-	err = json.Unmarshal(bytes, &sources)
+	err = diag.Decode(sourcePath, bytes, &sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sources: %w", err)
+	}
+
+	err = ValidateProjects(sources)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode sources")
+		return nil, err
 	}
 
 	return sources, nil
 }
 
-// GetItems creates an API request to the Item Source and returns an array of items to be added to OmniFocus
-func (source Source) GetItems() ([]omnifocus.NewOmniFocusItem, error) {
-	log.Printf("[source] Getting items from %s", source.URL)
-
-	url := source.createURL()
-	req, err := source.createRequest(url)
-	if err != nil {
-		return nil, err
+// ValidateProjects returns the first error of the projects of the sources that stops them from routing items
+func ValidateProjects(sources []Source) error {
+	for _, source := range sources {
+		if err := project.Validate(source.Projects); err != nil {
+			return fmt.Errorf("source %s: %w", source.Name, err)
+		}
 	}
 
+	return nil
+}
+
+// Options configures how items are fetched from a source
+type Options struct {
+	// The cache responses are revalidated against. Leave nil to always download the full response
+	Cache *Cache
+	// The directory incremental sources keep their watermark and snapshot of open items in
+	StateDir string
+	// Full ignores the watermark of incremental sources and fetches all of their items again
+	Full bool
+}
+
+// GetItems creates an API request to the Item Source and returns an array of items to be added to OmniFocus.
+// When a cache is given, the request is made conditional on the previously stored response, which is reused
+// if the source reports it hasn't changed. Incremental sources only request the items updated since their
+// last run and merge them into their stored snapshot, which is returned to be saved once the items were
+// synced. The snapshot is nil for every other source.
+func (source Source) GetItems(opts Options) ([]omnifocus.NewOmniFocusItem, *Snapshot, error) {
+	log.Printf("[source] Getting items from %s", source.URL)
+
 	client := http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	res, err := client.Do(req)
+	if source.Incremental != nil && opts.StateDir != "" {
+		items, snap, err := source.getIncremental(&client, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get items from %s: %w", source.Name, err)
+		}
+
+		return items, snap, nil
+	}
+
+	url := source.createURL(nil)
+	cache := opts.Cache
+	cached := cache.load(url)
+	res, body, err := source.fetch(&client, url, cached.conditionalHeader())
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %s", err)
+		return nil, nil, fmt.Errorf("failed to get items from %s: %w", source.Name, err)
 	}
 
-	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		if cached == nil {
+			return nil, nil, fmt.Errorf("failed to get items from %s: unexpected status %s without a cached response", source.Name, res.Status)
+		}
+
+		log.Printf("[source] %s has not changed, using the cached response", source.Name)
+		body = cached.Body
+	} else if err := cache.store(url, res.Header, body); err != nil {
+		log.Printf("[source] Failed to cache the response of %s: %s", source.Name, err)
+	}
 
-	body, err := io.ReadAll(res.Body)
+	items, err := source.parseResponse(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %s", err)
+		return nil, nil, fmt.Errorf("failed to get items from %s: %w", source.Name, err)
+	}
+
+	return items, nil, nil
+}
+
+// MissingPolicy returns what should happen to the tasks whose item is no longer returned by the source.
+// Without a state mapping, absence is the only sign that an item was closed, so they are completed. With one,
+// a task is only completed once the source reports it closed.
+func (source Source) MissingPolicy() (MissingPolicy, error) {
+	switch source.Missing {
+	case "":
+		if source.Response.State.Field != "" {
+			return MissingTag, nil
+		}
+
+		return MissingComplete, nil
+	case MissingComplete, MissingDrop, MissingTag, MissingIgnore, MissingDelete:
+		return source.Missing, nil
+	default:
+		return "", fmt.Errorf("unknown missing policy `%s` for %s", source.Missing, source.Name)
+	}
+}
+
+// GetCloseAction returns what should happen to a task whose item was closed for the given reason
+func (source Source) GetCloseAction(reason string) (CloseAction, error) {
+	action, ok := source.CloseActions[reason]
+	if !ok || action == "" {
+		return CloseComplete, nil
+	}
+
+	switch action {
+	case CloseComplete, CloseDrop, CloseDelete:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown close action `%s` for reason `%s`", action, reason)
+	}
+}
+
+// GetUnmatched returns where the items of the source that don't match any project go, falling back to
+// the given global setting when the source doesn't set its own
+func (source Source) GetUnmatched(global project.Fallback) project.Fallback {
+	if source.Unmatched.IsSet() {
+		return source.Unmatched
+	}
+
+	return global
+}
+
+// GetSchedule returns when the source is synced in daemon mode, falling back to the given global schedule
+// when the source doesn't set its own
+func (source Source) GetSchedule(global schedule.Schedule) schedule.Schedule {
+	if source.Schedule.IsSet() {
+		return source.Schedule
+	}
+
+	return global
+}
+
+// GetOwnership returns who wins when the field of a task was changed both upstream and in OmniFocus
+func (source Source) GetOwnership(field string) delta.Ownership {
+	if owner := source.Ownership[field]; owner != "" {
+		return owner
+	}
+
+	return delta.UpstreamWins
+}
+
+// ValidateOwnership returns an error if the source sets the ownership of an unknown field or uses an
+// unknown ownership
+func (source Source) ValidateOwnership() error {
+	for field, owner := range source.Ownership {
+		known := false
+		for _, f := range state.Fields {
+			known = known || f == field
+		}
+
+		if !known {
+			return fmt.Errorf("unknown field `%s` in the ownership of %s", field, source.Name)
+		}
+
+		if err := owner.Validate(); err != nil {
+			return fmt.Errorf("invalid ownership of `%s` for %s: %w", field, source.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// GetOrphanTag returns the tag added to tasks by the `tag` policy
+func (source Source) GetOrphanTag() string {
+	if source.OrphanTag != "" {
+		return source.OrphanTag
+	}
+
+	return defaultOrphanTag
+}
+
+// Owns returns whether a task with the given note was synced from the source, which links the task to its item
+// on the first line of the note. The link has to point to the host of the source's `URL` or share it with
+// a subdomain, like `github.com` for `api.github.com`, so tasks that merely carry the source's tags aren't its own.
+func (source Source) Owns(note string) bool {
+	link, err := url.Parse(strings.TrimSpace(strings.SplitN(note, "\n", 2)[0]))
+	if err != nil || link.Hostname() == "" {
+		return false
+	}
+
+	api, err := url.Parse(source.URL)
+	if err != nil || api.Hostname() == "" {
+		return false
+	}
+
+	item, host := strings.ToLower(link.Hostname()), strings.ToLower(api.Hostname())
+	return item == host || strings.HasSuffix(item, "."+host) || strings.HasSuffix(host, "."+item)
+}
+
+// Limits returns the completion limits for the source, falling back to the given defaults for any
+// limit the source doesn't set itself. A limit the source sets to 0 is turned off for it.
+func (source Source) Limits(defaults delta.Limits) delta.Limits {
+	limits := defaults
+	if source.Safety.MaxComplete != nil {
+		limits.MaxRemove = *source.Safety.MaxComplete
+	}
+
+	if source.Safety.MaxCompletePercent != nil {
+		limits.MaxRemovePercent = *source.Safety.MaxCompletePercent
 	}
 
-	return source.parseResponse(body)
+	return limits
 }
 
 // GetTags returns an array of all the tags associated with the sources
@@ -4,6 +4,9 @@ import (
   "testing"
   "net/http"
   "reflect"
+  "time"
+
+  "github.com/trevorpiltch/omnifocus-sync/internal/delta"
 )
 
 // MARK: SETUP
@@ -70,13 +73,13 @@ func requestsEqual(req1, req2 *http.Request) bool {
 }
 
 func TestCreateURL(t *testing.T) {
-  url := source1.createURL();
+  url := source1.createURL(nil);
 
   if url != "www.example.com?query=query" {
     t.Fatalf("Unexpected URL: %s", url);
   }
 
-  url = source2.createURL() 
+  url = source2.createURL(nil)
 
   if url != "www.example2.com" {
     t.Fatalf("Unexpected URL: %s", url);
@@ -84,7 +87,7 @@ func TestCreateURL(t *testing.T) {
 }
 
 func TestCreateRequest(t *testing.T) {
-  sourceURLString := source1.createURL();
+  sourceURLString := source1.createURL(nil);
   
   request, err := source1.createRequest(sourceURLString);
   if err != nil {
@@ -101,7 +104,7 @@ func TestCreateRequest(t *testing.T) {
     t.Fatalf("Unexpected request for source 1");
   }
 
-  sourceURLString = source2.createURL();
+  sourceURLString = source2.createURL(nil);
   
   request, err = source2.createRequest(sourceURLString);
   if err != nil {
@@ -138,3 +141,151 @@ func TestLoadSourcesSuccess(t *testing.T) {
   }
 }
 
+
+func TestStateIsClosed(t *testing.T) {
+  state := State{Field: "state", Open: []string{"open"}, Closed: []string{"closed"}}
+
+  closed, err := state.isClosed(map[string]interface{}{"state": "closed"})
+  if err != nil || !closed {
+    t.Fatalf("Expected closed, was %t (%v)", closed, err)
+  }
+
+  closed, err = state.isClosed(map[string]interface{}{"state": "open"})
+  if err != nil || closed {
+    t.Fatalf("Expected open, was %t (%v)", closed, err)
+  }
+
+  _, err = state.isClosed(map[string]interface{}{"state": "archived"})
+  if err == nil || err.Error() != "unknown `state` value `archived`" {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  // Only listing the open values makes everything else closed
+  state = State{Field: "completed", Open: []string{"false"}}
+  closed, err = state.isClosed(map[string]interface{}{"completed": true})
+  if err != nil || !closed {
+    t.Fatalf("Expected closed, was %t (%v)", closed, err)
+  }
+}
+
+func TestMissingPolicy(t *testing.T) {
+  policy, err := source1.MissingPolicy()
+  if err != nil || policy != MissingComplete {
+    t.Fatalf("Unexpected policy: %s (%v)", policy, err)
+  }
+
+  stateful := source1
+  stateful.Response.State = State{Field: "state", Closed: []string{"closed"}}
+  policy, err = stateful.MissingPolicy()
+  if err != nil || policy != MissingTag {
+    t.Fatalf("Unexpected policy: %s (%v)", policy, err)
+  }
+
+  stateful.Missing = "archive"
+  _, err = stateful.MissingPolicy()
+  if err == nil || err.Error() != "unknown missing policy `archive` for Source1" {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+}
+
+func TestCloseReason(t *testing.T) {
+  stateful := source1
+  stateful.Response.State = State{Field: "state", Closed: []string{"closed"}, Reason: "state_reason"}
+  stateful.CloseActions = map[string]CloseAction{"not_planned": CloseDrop, "duplicate": "archive"}
+
+  item, err := stateful.parseRecord(0, map[string]interface{}{
+    "Title": "title", "url": "url", "number": 1.0, "state": "closed", "state_reason": "not_planned",
+  })
+  if err != nil || !item.Closed || item.CloseReason != "not_planned" {
+    t.Fatalf("Unexpected item: %v (%v)", item, err)
+  }
+
+  action, err := stateful.GetCloseAction(item.CloseReason)
+  if err != nil || action != CloseDrop {
+    t.Fatalf("Unexpected action: %s (%v)", action, err)
+  }
+
+  action, err = stateful.GetCloseAction("completed")
+  if err != nil || action != CloseComplete {
+    t.Fatalf("Unexpected action: %s (%v)", action, err)
+  }
+
+  _, err = stateful.GetCloseAction("duplicate")
+  if err == nil || err.Error() != "unknown close action `archive` for reason `duplicate`" {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+}
+
+func TestDue(t *testing.T) {
+  due := source1
+  due.Response.Due = "due_on"
+
+  item, err := due.parseRecord(0, map[string]interface{}{
+    "Title": "title", "url": "url", "number": 1.0, "due_on": "2023-11-14T10:00:00Z",
+  })
+  if err != nil || item.DueDateMS != time.Date(2023, 11, 14, 10, 0, 0, 0, time.UTC).UnixMilli() {
+    t.Fatalf("Unexpected item: %v (%v)", item, err)
+  }
+
+  _, err = due.parseRecord(0, map[string]interface{}{
+    "Title": "title", "url": "url", "number": 1.0, "due_on": "soon",
+  })
+  if err == nil || err.Error() != "item 0 has an invalid `due_on` date `soon`" {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+}
+
+func TestOwnership(t *testing.T) {
+  owned := source1
+  owned.Ownership = map[string]delta.Ownership{"note": delta.Append}
+
+  if err := owned.ValidateOwnership(); err != nil {
+    t.Fatalf("Unexpected error: %s", err)
+  }
+
+  if owned.GetOwnership("note") != delta.Append || owned.GetOwnership("name") != delta.UpstreamWins {
+    t.Fatalf("Unexpected ownership: %v", owned.Ownership)
+  }
+
+  owned.Ownership = map[string]delta.Ownership{"title": delta.LocalWins}
+  err := owned.ValidateOwnership()
+  if err == nil || err.Error() != "unknown field `title` in the ownership of " + owned.Name {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+}
+
+func TestOwns(t *testing.T) {
+  github := Source{Name: "GitHub", URL: "https://api.github.com/repos/trevorpiltch/omnifocus-sync/issues"}
+
+  tests := []struct {
+    note string
+    expected bool
+  }{
+    {"https://github.com/trevorpiltch/omnifocus-sync/issues/257", true},
+    {"https://github.com/trevorpiltch/omnifocus-sync/issues/257\nNotes of my own", true},
+    {"https://api.github.com/repos/trevorpiltch/omnifocus-sync/issues/257", true},
+    {"https://gitlab.com/trevorpiltch/omnifocus-sync/-/issues/3", false},
+    {"Call the bank", false},
+    {"", false},
+  }
+
+  for _, test := range tests {
+    if got := github.Owns(test.note); got != test.expected {
+      t.Errorf("Expected the ownership of %q to be %v, was: %v", test.note, test.expected, got)
+    }
+  }
+}
+
+func TestLimits(t *testing.T) {
+  defaults := delta.Limits{MaxRemove: 10, MaxRemovePercent: 50}
+  if limits := source1.Limits(defaults); limits != defaults {
+    t.Fatalf("Expected the default limits, was: %+v", limits)
+  }
+
+  max, off := 5, 0.0
+  limited := source1
+  limited.Safety = Safety{MaxComplete: &max, MaxCompletePercent: &off}
+  if limits := limited.Limits(defaults); limits != (delta.Limits{MaxRemove: 5}) {
+    t.Fatalf("Unexpected limits: %+v", limits)
+  }
+}
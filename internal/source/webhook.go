@@ -0,0 +1,196 @@
+package source
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Provider is the service that sends the webhooks of a source
+type Provider string
+
+const (
+	ProviderGitHub   Provider = "github"
+	ProviderGitLab   Provider = "gitlab"
+	ProviderLinear   Provider = "linear"
+	ProviderShortcut Provider = "shortcut"
+	ProviderJira     Provider = "jira"
+)
+
+// signature says how a provider signs its deliveries
+type signature struct {
+	// The header carrying the signature
+	header string
+	// What comes before the signature in the header, e.g. `sha256=`
+	prefix string
+	// Whether the header holds the hex HMAC-SHA256 of the body with the secret, instead of the secret itself
+	hmac bool
+}
+
+// signatures holds how every provider signs its deliveries. GitLab doesn't sign them, it sends the secret token.
+var signatures = map[Provider]signature{
+	ProviderGitHub:   {header: "X-Hub-Signature-256", prefix: "sha256=", hmac: true},
+	ProviderGitLab:   {header: "X-Gitlab-Token"},
+	ProviderLinear:   {header: "Linear-Signature", hmac: true},
+	ProviderShortcut: {header: "Payload-Signature", hmac: true},
+	ProviderJira:     {header: "X-Hub-Signature", prefix: "sha256=", hmac: true},
+}
+
+// Webhook configures the webhooks a source sends to the daemon
+type Webhook struct {
+	// The service sending the webhooks: `github`, `gitlab`, `linear`, `shortcut` or `jira`
+	Provider Provider `json:"Provider"`
+	// The secret the deliveries are signed with, or GitLab's secret token
+	Secret string `json:"Secret"`
+	// The field of the payload holding the item, in the same shape as the items the source returns when polled.
+	// Nested fields are separated by dots. Defaults to the field of the provider, e.g. `issue` for GitHub
+	Field string `json:"Field"`
+}
+
+// MARK: Private helper methods
+// validate returns an error if deliveries of the webhook can never be accepted
+func (w Webhook) validate() error {
+	if _, ok := signatures[w.Provider]; !ok {
+		return fmt.Errorf("unknown provider `%s`, expected github, gitlab, linear, shortcut or jira", w.Provider)
+	}
+
+	if w.Secret == "" {
+		return fmt.Errorf("`Secret` is required so that only %s can trigger syncs", w.Provider)
+	}
+
+	return nil
+}
+
+// field returns the field of the payload holding the item, or an empty string when the delivery doesn't carry
+// a whole item, like Shortcut's, which only lists the changes
+func (w Webhook) field(payload map[string]interface{}) string {
+	if w.Field != "" {
+		return w.Field
+	}
+
+	switch w.Provider {
+	case ProviderGitHub:
+		for _, field := range []string{"issue", "pull_request"} {
+			if payload[field] != nil {
+				return field
+			}
+		}
+	case ProviderGitLab:
+		// Comments carry the issue or merge request they were made on
+		if payload["object_kind"] == "note" {
+			for _, field := range []string{"issue", "merge_request"} {
+				if payload[field] != nil {
+					return field
+				}
+			}
+
+			return ""
+		}
+
+		return "object_attributes"
+	case ProviderLinear:
+		if payload["type"] == "Issue" {
+			return "data"
+		}
+	case ProviderJira:
+		return "issue"
+	}
+
+	return ""
+}
+
+// sign returns the hex HMAC-SHA256 of the body with the secret
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MARK: Public methods
+// ValidateWebhook returns an error if the source's webhook is misconfigured
+func (source Source) ValidateWebhook() error {
+	if source.Webhook == nil {
+		return nil
+	}
+
+	err := source.Webhook.validate()
+	if err != nil {
+		return fmt.Errorf("invalid webhook for %s: %w", source.Name, err)
+	}
+
+	return nil
+}
+
+// VerifyWebhook returns an error unless the delivery was signed with the secret of the source's webhook
+func (source Source) VerifyWebhook(header http.Header, body []byte) error {
+	if source.Webhook == nil {
+		return fmt.Errorf("%s has no webhook", source.Name)
+	}
+
+	w := source.Webhook
+	sig, ok := signatures[w.Provider]
+	if !ok || w.Secret == "" {
+		return fmt.Errorf("invalid webhook for %s", source.Name)
+	}
+
+	value := header.Get(sig.header)
+	if value == "" {
+		return fmt.Errorf("missing `%s` header", sig.header)
+	}
+
+	expected := w.Secret
+	if sig.hmac {
+		expected = sig.prefix + sign(w.Secret, body)
+		value = strings.ToLower(value)
+	}
+
+	if !hmac.Equal([]byte(value), []byte(expected)) {
+		return fmt.Errorf("invalid `%s` header", sig.header)
+	}
+
+	return nil
+}
+
+// ParseWebhook returns the records of the items a delivery of the source's webhook concerns, by the URL of
+// their item, and whether the delivery calls for a sync at all. The records are mapped by the source's
+// `Response` like polled items are. No records means the whole source is synced, which is the case for
+// deliveries that don't carry an item in the shape the source returns when polled.
+func (source Source) ParseWebhook(header http.Header, body []byte) (map[string]map[string]interface{}, bool, error) {
+	if source.Webhook == nil {
+		return nil, false, fmt.Errorf("%s has no webhook", source.Name)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	w := source.Webhook
+	if w.Provider == ProviderGitHub && header.Get("X-GitHub-Event") == "ping" {
+		return nil, false, nil
+	}
+
+	field := w.field(payload)
+	if field == "" {
+		return nil, true, nil
+	}
+
+	record, ok := lookup(payload, field).(map[string]interface{})
+	if !ok {
+		log.Printf("[source] The webhook of %s has no `%s` object, syncing the whole source", source.Name, field)
+		return nil, true, nil
+	}
+
+	items, err := source.ParseRecords([]map[string]interface{}{record})
+	if err != nil {
+		log.Printf("[source] The `%s` of the webhook of %s can't be mapped like a polled item, syncing the whole source: %s", field, source.Name, err)
+		return nil, true, nil
+	}
+
+	return map[string]map[string]interface{}{items[0].Note: record}, true, nil
+}
@@ -0,0 +1,50 @@
+package source
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// MARK: Webhook tests
+// Tests that `Field` picks the item out of nested payloads
+func TestParseWebhookField(t *testing.T) {
+	source := Source{
+		Name:     "Tracker",
+		Response: source1.Response,
+		Webhook:  &Webhook{Provider: ProviderGitHub, Secret: "secret", Field: "event.item"},
+	}
+
+	body := []byte(`{"event": {"item": {"Title": "title", "url": "https://example.com/1", "number": 1}}}`)
+	records, sync, err := source.ParseWebhook(http.Header{}, body)
+	expected := map[string]map[string]interface{}{
+		"https://example.com/1": {"Title": "title", "url": "https://example.com/1", "number": float64(1)},
+	}
+	if err != nil || !sync || !reflect.DeepEqual(records, expected) {
+		t.Errorf("Unexpected records: %v, %t, %v", records, sync, err)
+	}
+
+	// Without the field the whole source is synced
+	records, sync, err = source.ParseWebhook(http.Header{}, []byte(`{"event": {}}`))
+	if err != nil || !sync || records != nil {
+		t.Errorf("Unexpected records: %v, %t, %v", records, sync, err)
+	}
+}
+
+// Tests that signatures are compared regardless of the case of their hex digits
+func TestVerifyWebhook(t *testing.T) {
+	source := Source{Name: "Linear", Webhook: &Webhook{Provider: ProviderLinear, Secret: "secret"}}
+	body := []byte(`{"type": "Issue"}`)
+
+	header := http.Header{}
+	header.Set("Linear-Signature", "7E0F2E1E")
+	if err := source.VerifyWebhook(header, body); err == nil || err.Error() != "invalid `Linear-Signature` header" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	header.Set("Linear-Signature", strings.ToUpper(sign("secret", body)))
+	if err := source.VerifyWebhook(header, body); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
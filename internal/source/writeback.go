@@ -0,0 +1,236 @@
+package source
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// Adapter is the upstream action that closes an item whose task was completed in OmniFocus
+type Adapter string
+
+const (
+	// AdapterHTTP sends the request configured by `Method`, `URL` and `Body`
+	AdapterHTTP Adapter = "http"
+	// AdapterGitHub closes the GitHub issue, as not planned when its task was dropped
+	AdapterGitHub Adapter = "github"
+	// AdapterShortcut moves the Shortcut story to the workflow state in `Value`
+	AdapterShortcut Adapter = "shortcut"
+)
+
+// ConflictPolicy says which side wins when an item was updated upstream after its task was closed in OmniFocus
+type ConflictPolicy string
+
+const (
+	// ConflictUpstream reopens the task, since the item is still open upstream
+	ConflictUpstream ConflictPolicy = "upstream"
+	// ConflictLocal closes the item upstream anyway
+	ConflictLocal ConflictPolicy = "local"
+	// ConflictSkip leaves both the task and the item alone
+	ConflictSkip ConflictPolicy = "skip"
+)
+
+// shortcutStoryURL is the Shortcut API endpoint of a story
+const shortcutStoryURL = "https://api.app.shortcut.com/api/v3/stories/%s"
+
+// Writeback configures a source to close its items upstream when their tasks are completed or dropped
+// in OmniFocus, instead of reopening or re-adding the tasks
+type Writeback struct {
+	// The upstream action: `github`, `shortcut` or `http`. Defaults to `http`
+	Adapter Adapter `json:"Adapter"`
+	// The HTTP method of the `http` adapter. Defaults to `PATCH`
+	Method string `json:"Method"`
+	// A Go template over the fields of an item that gives the URL of the `http` adapter, e.g. `{{.url}}`
+	URL string `json:"URL"`
+	// A Go template over the fields of an item that gives the JSON body of the `http` adapter
+	Body string `json:"Body"`
+	// The value native adapters set, e.g. the ID of the Shortcut workflow state that means done
+	Value string `json:"Value"`
+	// The RFC 3339 field holding when an item was last updated upstream, used to detect conflicts.
+	// Defaults to `Incremental.UpdatedField`
+	UpdatedField string `json:"UpdatedField"`
+	// Who wins when an item was updated upstream after its task was closed: `upstream`, `local` or `skip`.
+	// Defaults to `upstream`
+	Conflict ConflictPolicy `json:"Conflict"`
+}
+
+// MARK: Private helper methods
+// adapter returns the upstream action of the write back
+func (w Writeback) adapter() Adapter {
+	if w.Adapter == "" {
+		return AdapterHTTP
+	}
+
+	return w.Adapter
+}
+
+// render executes the template over the fields of the item
+func render(text string, fields map[string]interface{}) (string, error) {
+	t, err := template.New("writeback").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, fields)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// request returns the method, URL and body of the request that closes the item upstream
+func (w Writeback) request(item omnifocus.NewOmniFocusItem, dropped bool) (string, string, []byte, error) {
+	switch w.adapter() {
+	case AdapterGitHub:
+		url, ok := item.Fields["url"].(string)
+		if !ok {
+			return "", "", nil, fmt.Errorf("item has no string `url` field")
+		}
+
+		reason := "completed"
+		if dropped {
+			reason = "not_planned"
+		}
+
+		return http.MethodPatch, url, []byte(fmt.Sprintf(`{"state":"closed","state_reason":"%s"}`, reason)), nil
+	case AdapterShortcut:
+		id, ok := item.Fields["id"].(float64)
+		if !ok {
+			return "", "", nil, fmt.Errorf("item has no numeric `id` field")
+		}
+
+		url := fmt.Sprintf(shortcutStoryURL, strconv.FormatFloat(id, 'f', -1, 64))
+		return http.MethodPut, url, []byte(fmt.Sprintf(`{"workflow_state_id":%s}`, w.Value)), nil
+	default:
+		url, err := render(w.URL, item.Fields)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to render write back URL: %w", err)
+		}
+
+		body, err := render(w.Body, item.Fields)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("failed to render write back body: %w", err)
+		}
+
+		method := w.Method
+		if method == "" {
+			method = http.MethodPatch
+		}
+
+		return strings.ToUpper(method), url, []byte(body), nil
+	}
+}
+
+// validate returns an error if the write back can never close an item
+func (w Writeback) validate() error {
+	switch w.Conflict {
+	case "", ConflictUpstream, ConflictLocal, ConflictSkip:
+	default:
+		return fmt.Errorf("unknown conflict policy `%s`", w.Conflict)
+	}
+
+	switch w.adapter() {
+	case AdapterGitHub:
+		return nil
+	case AdapterShortcut:
+		if _, err := strconv.Atoi(w.Value); err != nil {
+			return fmt.Errorf("the shortcut adapter needs the ID of a workflow state as `Value`")
+		}
+
+		return nil
+	case AdapterHTTP:
+		if w.URL == "" {
+			return fmt.Errorf("the http adapter needs a `URL`")
+		}
+
+		for _, text := range []string{w.URL, w.Body} {
+			if _, err := template.New("writeback").Parse(text); err != nil {
+				return fmt.Errorf("invalid template `%s`: %w", text, err)
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown adapter `%s`", w.Adapter)
+	}
+}
+
+// MARK: Public methods
+// ValidateWriteback returns an error if the source's write back is misconfigured
+func (source Source) ValidateWriteback() error {
+	if source.Writeback == nil {
+		return nil
+	}
+
+	err := source.Writeback.validate()
+	if err != nil {
+		return fmt.Errorf("invalid write back for %s: %w", source.Name, err)
+	}
+
+	return nil
+}
+
+// GetConflictPolicy returns who wins when an item was updated upstream after its task was closed
+func (source Source) GetConflictPolicy() ConflictPolicy {
+	if source.Writeback == nil || source.Writeback.Conflict == "" {
+		return ConflictUpstream
+	}
+
+	return source.Writeback.Conflict
+}
+
+// Conflicts returns whether the item was updated upstream after its task was closed at the given time.
+// Without an update field or a close time there's nothing to compare, so there is no conflict.
+func (source Source) Conflicts(item omnifocus.NewOmniFocusItem, closedAt time.Time) bool {
+	field := ""
+	if source.Writeback != nil {
+		field = source.Writeback.UpdatedField
+	}
+	if field == "" && source.Incremental != nil {
+		field = source.Incremental.UpdatedField
+	}
+
+	value, ok := item.Fields[field].(string)
+	if field == "" || !ok || closedAt.IsZero() {
+		return false
+	}
+
+	updated, err := time.Parse(time.RFC3339, value)
+	return err == nil && updated.After(closedAt)
+}
+
+// CloseUpstream closes the item at its source, because its task was completed or dropped in OmniFocus
+func (source Source) CloseUpstream(item omnifocus.NewOmniFocusItem, dropped bool) error {
+	if source.Writeback == nil {
+		return fmt.Errorf("%s has no write back", source.Name)
+	}
+
+	method, url, body, err := source.Writeback.request(item, dropped)
+	if err != nil {
+		return fmt.Errorf("failed to close %s upstream: %w", item.Name, err)
+	}
+
+	log.Printf("[source] Closing %s upstream: %s %s", item.Name, method, url)
+
+	client := http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	_, _, err = source.send(&client, method, url, header, body)
+	if err != nil {
+		return fmt.Errorf("failed to close %s upstream: %w", item.Name, err)
+	}
+
+	return nil
+}
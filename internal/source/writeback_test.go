@@ -0,0 +1,126 @@
+package source
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+)
+
+// MARK: Writeback tests
+// Tests that the GitHub adapter closes the issue, as not planned when its task was dropped
+func TestCloseUpstreamGitHub(t *testing.T) {
+	stubSleep(t)
+	server, calls := sequenceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPatch || r.URL.Path != "/repos/owner/repo/issues/7" {
+			t.Fatalf("Unexpected request: %s %s", r.Method, r.URL)
+		}
+
+		if string(body) != `{"state":"closed","state_reason":"not_planned"}` {
+			t.Fatalf("Unexpected body: %s", body)
+		}
+
+		if r.Header.Get("Authorization") != "token secret" || r.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("Unexpected headers: %v", r.Header)
+		}
+
+		status(200, `{}`)(w, r)
+	})
+
+	source := Source{
+		Name:      "GitHub",
+		Headers:   []Header{{Key: "Authorization", Value: "token secret"}},
+		Writeback: &Writeback{Adapter: AdapterGitHub},
+	}
+
+	item := omnifocus.NewOmniFocusItem{
+		Name:   "[7] Fix it",
+		Fields: map[string]interface{}{"url": server.URL + "/repos/owner/repo/issues/7"},
+	}
+
+	err := source.CloseUpstream(item, true)
+	if err != nil || *calls != 1 {
+		t.Fatalf("Unexpected error after %d calls: %v", *calls, err)
+	}
+}
+
+// Tests that the http adapter renders its URL and body from the fields of the item
+func TestCloseUpstreamHTTP(t *testing.T) {
+	stubSleep(t)
+	server, _ := sequenceServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/tickets/42/close" || string(body) != `{"by":"omnisync"}` {
+			t.Fatalf("Unexpected request: %s %s %s", r.Method, r.URL, body)
+		}
+
+		status(204, "")(w, r)
+	})
+
+	source := Source{
+		Name: "Tickets",
+		Writeback: &Writeback{
+			Method: "post",
+			URL:    server.URL + "/tickets/{{.id}}/close",
+			Body:   `{"by":"omnisync"}`,
+		},
+	}
+
+	item := omnifocus.NewOmniFocusItem{Name: "[42] Ticket", Fields: map[string]interface{}{"id": "42"}}
+	if err := source.CloseUpstream(item, false); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// Items without the fields used by the templates fail instead of hitting a wrong URL
+	err := source.CloseUpstream(omnifocus.NewOmniFocusItem{Name: "[43] Ticket"}, false)
+	if err == nil {
+		t.Fatalf("Expected an error for an item without an `id`")
+	}
+}
+
+// Tests that an item updated upstream after its task was closed is a conflict
+func TestConflicts(t *testing.T) {
+	source := Source{Writeback: &Writeback{UpdatedField: "updated_at"}}
+	item := omnifocus.NewOmniFocusItem{Fields: map[string]interface{}{"updated_at": "2023-11-14T12:00:00Z"}}
+
+	closedAt := time.Date(2023, 11, 14, 11, 0, 0, 0, time.UTC)
+	if !source.Conflicts(item, closedAt) {
+		t.Fatalf("Expected a conflict for an item updated after its task was closed")
+	}
+
+	if source.Conflicts(item, closedAt.Add(2*time.Hour)) {
+		t.Fatalf("Expected no conflict for an item updated before its task was closed")
+	}
+
+	if source.Conflicts(item, time.Time{}) {
+		t.Fatalf("Expected no conflict without a close time")
+	}
+
+	if source.GetConflictPolicy() != ConflictUpstream {
+		t.Fatalf("Unexpected default policy: %s", source.GetConflictPolicy())
+	}
+}
+
+// Tests that misconfigured write backs are reported before anything is closed
+func TestValidateWriteback(t *testing.T) {
+	tests := map[string]Writeback{
+		"invalid write back for Broken: the http adapter needs a `URL`":                                   {},
+		"invalid write back for Broken: unknown adapter `jira`":                                           {Adapter: "jira"},
+		"invalid write back for Broken: unknown conflict policy `mine`":                                   {Adapter: AdapterGitHub, Conflict: "mine"},
+		"invalid write back for Broken: the shortcut adapter needs the ID of a workflow state as `Value`": {Adapter: AdapterShortcut},
+	}
+
+	for expected, writeback := range tests {
+		writeback := writeback
+		err := Source{Name: "Broken", Writeback: &writeback}.ValidateWriteback()
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Expected %s, was: %v", expected, err)
+		}
+	}
+
+	if err := (Source{Writeback: &Writeback{Adapter: AdapterShortcut, Value: "500000011"}}).ValidateWriteback(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,357 @@
+// Package state keeps what OmniSync last synced for every item between runs, so that the upstream item,
+// its last synced state and its OmniFocus task can be compared three ways.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+)
+
+// version is the format of the state file, bumped whenever it changes incompatibly
+const version = 1
+
+// Fields are the fields of a task that are merged three ways
+var Fields = []string{"name", "note", "tags", "due"}
+
+// Record is the last synced state of one item of a source
+type Record struct {
+	// ExternalID identifies the item at its source, its URL
+	ExternalID string `json:"externalId"`
+	// TaskID is the ID of the item's task in OmniFocus
+	TaskID string `json:"taskId"`
+	// Name is the name of the task when it was last synced
+	Name string `json:"name"`
+	// Hashes are the hashes of the synced fields of the item, keyed by field
+	Hashes map[string]string `json:"hashes"`
+	// Values are the upstream values of the merged fields when the item was last synced, keyed by field
+	Values map[string]string `json:"values,omitempty"`
+	// CreatedAt is when the item was first synced
+	CreatedAt time.Time `json:"createdAt"`
+	// SyncedAt is when the item was last synced
+	SyncedAt time.Time `json:"syncedAt"`
+}
+
+// Store holds the records of every source
+type Store struct {
+	path string
+	// Version is the format of the state file
+	Version int `json:"version"`
+	// Sources holds the records of each source by its name, then by the external ID of the item
+	Sources map[string]map[string]Record `json:"sources"`
+}
+
+// Comparison is the outcome of comparing the items of a source with their tasks and last synced state
+type Comparison struct {
+	// Desired are the items that should have a task, without the ones whose task was removed in OmniFocus
+	Desired []omnifocus.NewOmniFocusItem
+	// Current are the tasks of the source, keyed by the item they were last synced with
+	Current []omnifocus.Item
+	// Removed are the items whose task was completed, dropped or deleted in OmniFocus while the item didn't
+	// change upstream, so they aren't added again
+	Removed []omnifocus.NewOmniFocusItem
+}
+
+// MARK: Private helper methods
+// hash returns the hash of the value
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// joinTags returns the tags as a sorted list of lines
+func joinTags(tags []string) string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\n")
+}
+
+// formatDue returns the due date as RFC 3339, or an empty string without one
+func formatDue(due time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+
+	return due.UTC().Format(time.RFC3339)
+}
+
+// records returns the records of the source, creating them if needed
+func (s *Store) records(source string) map[string]Record {
+	records, ok := s.Sources[source]
+	if !ok {
+		records = map[string]Record{}
+		s.Sources[source] = records
+	}
+
+	return records
+}
+
+// MARK: Public methods
+// Values returns the values of the merged fields of the item
+func Values(item omnifocus.NewOmniFocusItem) map[string]string {
+	due := time.Time{}
+	if item.DueDateMS != 0 {
+		due = time.UnixMilli(item.DueDateMS)
+	}
+
+	return map[string]string{
+		"name": item.Name,
+		"note": item.Note,
+		"tags": joinTags(item.Tags),
+		"due":  formatDue(due),
+	}
+}
+
+// TaskValues returns the values of the merged fields of the task
+func TaskValues(task omnifocus.Item) map[string]string {
+	return map[string]string{
+		"name": task.Name,
+		"note": task.Note,
+		"tags": joinTags(task.Tags),
+		"due":  formatDue(task.DueDate),
+	}
+}
+
+// Hashes returns the hashes of the synced fields of the item
+func Hashes(item omnifocus.NewOmniFocusItem) map[string]string {
+	destination := item.ProjectName
+	if item.Inbox {
+		destination = ""
+	}
+
+	return map[string]string{
+		"name":    hash(item.Name),
+		"note":    hash(item.Note),
+		"tags":    hash(joinTags(item.Tags)),
+		"project": hash(destination),
+	}
+}
+
+// Changed returns whether the item changed upstream since it was last synced
+func (r Record) Changed(item omnifocus.NewOmniFocusItem) bool {
+	for field, h := range Hashes(item) {
+		if r.Hashes[field] != h {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Merge returns the changes that bring the task in line with a three-way merge of the values the item was
+// last synced with, its values upstream and the values of the task, and whether there are any. Records
+// without synced values, from before values were kept, never change the task.
+func (r Record) Merge(item omnifocus.NewOmniFocusItem, task omnifocus.Item, owner func(field string) delta.Ownership) (omnifocus.ItemUpdate, bool) {
+	u := omnifocus.ItemUpdate{ID: task.ID}
+	if r.Values == nil {
+		return u, false
+	}
+
+	upstream, local := Values(item), TaskValues(task)
+
+	changed := false
+	for _, field := range Fields {
+		merged := delta.Merge(r.Values[field], upstream[field], local[field], owner(field))
+		if merged == local[field] {
+			continue
+		}
+
+		changed = true
+		switch field {
+		case "name":
+			u.Name = &merged
+		case "note":
+			u.Note = &merged
+		case "tags":
+			// Splitting an empty set would leave a tag without a name
+			u.Tags = []string{}
+			if merged != "" {
+				u.Tags = strings.Split(merged, "\n")
+			}
+		case "due":
+			var ms int64
+			if due, err := time.Parse(time.RFC3339, merged); err == nil {
+				ms = due.UnixMilli()
+			}
+			u.DueDateMS = &ms
+		}
+	}
+
+	return u, changed
+}
+
+// Load returns the store kept in the given directory, or an empty one on the first run
+func Load(dir string) (*Store, error) {
+	s := &Store{
+		path:    path.Join(dir, "state.json"),
+		Version: version,
+		Sources: map[string]map[string]Record{},
+	}
+
+	bytes, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	err = json.Unmarshal(bytes, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	if s.Version != version {
+		return nil, fmt.Errorf("unsupported state version %d in %s", s.Version, s.path)
+	}
+
+	if s.Sources == nil {
+		s.Sources = map[string]map[string]Record{}
+	}
+
+	return s, nil
+}
+
+// Save stores the records in the directory the store was loaded from
+func (s *Store) Save() error {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	err = os.MkdirAll(path.Dir(s.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a half written state behind
+	err = os.WriteFile(s.path+".tmp", bytes, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+// Get returns the record of the item of the source with the given external ID
+func (s *Store) Get(source, id string) (Record, bool) {
+	r, ok := s.Sources[source][id]
+	return r, ok
+}
+
+// Put stores the record of an item of the source, keeping when it was first synced
+func (s *Store) Put(source string, r Record) {
+	records := s.records(source)
+	if existing, ok := records[r.ExternalID]; ok && !existing.CreatedAt.IsZero() {
+		r.CreatedAt = existing.CreatedAt
+	}
+
+	records[r.ExternalID] = r
+}
+
+// Delete removes the record of the item of the source with the given external ID
+func (s *Store) Delete(source, id string) {
+	delete(s.Sources[source], id)
+}
+
+// ExternalIDs returns the sorted external IDs of the items of the source that have a record
+func (s *Store) ExternalIDs(source string) []string {
+	ids := make([]string, 0, len(s.Sources[source]))
+	for id := range s.Sources[source] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Compare matches the items of the source with their current tasks through their records. A task is
+// matched by its ID, so a task renamed on either side is still the task of its item. An open item whose
+// task is gone was removed in OmniFocus, and is only added again when it changed upstream since.
+func (s *Store) Compare(source string, desired []omnifocus.NewOmniFocusItem, current []omnifocus.Item) Comparison {
+	byID := map[string]int{}
+	for i, task := range current {
+		byID[task.ID] = i
+	}
+
+	c := Comparison{Current: append([]omnifocus.Item{}, current...)}
+	for _, item := range desired {
+		r, ok := s.Get(source, item.Note)
+		if !ok || r.TaskID == "" {
+			c.Desired = append(c.Desired, item)
+			continue
+		}
+
+		i, found := byID[r.TaskID]
+		switch {
+		case found:
+			if c.Current[i].Name != item.Name {
+				c.Current[i].SyncKey = item.Key()
+			}
+			c.Desired = append(c.Desired, item)
+		case item.Closed || r.Changed(item):
+			c.Desired = append(c.Desired, item)
+		default:
+			log.Printf("[state] %s was removed in OmniFocus and hasn't changed since, not adding it again", item.Name)
+			c.Removed = append(c.Removed, item)
+		}
+	}
+
+	return c
+}
+
+// Update records the tasks of the items of the source after a sync. Items without a task keep their
+// record, so a task removed in OmniFocus stays removed, and records of items that are closed or no longer
+// returned are dropped.
+func (s *Store) Update(source string, desired []omnifocus.NewOmniFocusItem, tasks map[string]omnifocus.Item, at time.Time) {
+	s.UpdateItems(source, desired, tasks, at)
+
+	returned := map[string]bool{}
+	for _, item := range desired {
+		returned[item.Note] = true
+	}
+
+	records := s.records(source)
+	for id := range records {
+		if !returned[id] {
+			delete(records, id)
+		}
+	}
+}
+
+// UpdateItems records the tasks of the items after a sync of only some items of the source, like Update
+// but keeping the records of every other item
+func (s *Store) UpdateItems(source string, desired []omnifocus.NewOmniFocusItem, tasks map[string]omnifocus.Item, at time.Time) {
+	records := s.records(source)
+
+	for _, item := range desired {
+		if item.Closed {
+			delete(records, item.Note)
+			continue
+		}
+
+		task, ok := tasks[item.Key()]
+		if !ok {
+			continue
+		}
+
+		s.Put(source, Record{
+			ExternalID: item.Note,
+			TaskID:     task.ID,
+			Name:       item.Name,
+			Hashes:     Hashes(item),
+			Values:     Values(item),
+			CreatedAt:  at,
+			SyncedAt:   at,
+		})
+	}
+}
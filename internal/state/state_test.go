@@ -0,0 +1,195 @@
+package state
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	omnifocus "github.com/trevorpiltch/omnifocus-sync/internal/OF"
+	"github.com/trevorpiltch/omnifocus-sync/internal/delta"
+)
+
+var (
+	issue1 = omnifocus.NewOmniFocusItem{Name: "[1] One", Note: "https://example.com/1", ProjectName: "OmniSync", Tags: []string{"github"}}
+	issue2 = omnifocus.NewOmniFocusItem{Name: "[2] Two", Note: "https://example.com/2", ProjectName: "OmniSync", Tags: []string{"github"}}
+	synced = time.Date(2023, 11, 14, 10, 0, 0, 0, time.UTC)
+)
+
+// MARK: Store tests
+// Tests that records survive a save and load, and keep when they were first synced
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}}, synced)
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}}, synced.Add(time.Hour))
+	if err := s.Save(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	r, ok := loaded.Get("GitHub", issue1.Note)
+	if !ok || r.TaskID != "a1" || !r.CreatedAt.Equal(synced) || !r.SyncedAt.Equal(synced.Add(time.Hour)) {
+		t.Fatalf("Unexpected record: %+v", r)
+	}
+
+	if r.Changed(issue1) {
+		t.Fatalf("Expected the record to match the item it was synced with")
+	}
+
+	renamed := issue1
+	renamed.Name = "[1] One renamed"
+	if !r.Changed(renamed) {
+		t.Fatalf("Expected a renamed item to have changed")
+	}
+
+	err = os.WriteFile(path.Join(dir, "state.json"), []byte(`{"version": 99}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(dir); err == nil {
+		t.Fatalf("Expected an error for an unknown version")
+	}
+}
+
+// Tests that a task renamed on either side is still matched with its item through its ID
+func TestCompareRenamed(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}}, synced)
+
+	renamed := issue1
+	renamed.Name = "[1] One renamed upstream"
+	c := s.Compare("GitHub", []omnifocus.NewOmniFocusItem{renamed}, []omnifocus.Item{{ID: "a1", Name: "[1] My own name"}})
+
+	if len(c.Desired) != 1 || len(c.Current) != 1 || c.Current[0].Key() != renamed.Key() {
+		t.Fatalf("Unexpected comparison: %+v", c)
+	}
+}
+
+// Tests that a task removed in OmniFocus is only added again once its item changes upstream
+func TestCompareRemoved(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	items := []omnifocus.NewOmniFocusItem{issue1, issue2}
+	s.Update("GitHub", items, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}, issue2.Key(): {ID: "a2"}}, synced)
+
+	c := s.Compare("GitHub", items, []omnifocus.Item{{ID: "a2", Name: issue2.Name}})
+	if len(c.Desired) != 1 || c.Desired[0].Key() != issue2.Key() || len(c.Removed) != 1 {
+		t.Fatalf("Unexpected comparison: %+v", c)
+	}
+
+	moved := issue1
+	moved.ProjectName = "Elsewhere"
+	c = s.Compare("GitHub", []omnifocus.NewOmniFocusItem{moved}, nil)
+	if len(c.Desired) != 1 || len(c.Removed) != 0 {
+		t.Fatalf("Expected a changed item to be added again: %+v", c)
+	}
+
+	// Items that were never synced are always desired
+	c = s.Compare("Other", items, nil)
+	if len(c.Desired) != 2 {
+		t.Fatalf("Unexpected comparison: %+v", c)
+	}
+}
+
+// Tests that records of closed items and items that are no longer returned are dropped
+func TestUpdateDropsRecords(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1, issue2}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}, issue2.Key(): {ID: "a2"}}, synced)
+
+	closed := issue1
+	closed.Closed = true
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{closed}, map[string]omnifocus.Item{}, synced)
+
+	if len(s.Sources["GitHub"]) != 0 {
+		t.Fatalf("Expected no records, was: %v", s.Sources["GitHub"])
+	}
+}
+
+// Tests that updating some items keeps the records of the others
+func TestUpdateItemsKeepsRecords(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1, issue2}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}, issue2.Key(): {ID: "a2"}}, synced)
+
+	renamed := issue2
+	renamed.Name = "[2] Renamed"
+	s.UpdateItems("GitHub", []omnifocus.NewOmniFocusItem{renamed}, map[string]omnifocus.Item{renamed.Key(): {ID: "a2"}}, synced)
+
+	if r, ok := s.Get("GitHub", issue1.Note); !ok || r.TaskID != "a1" {
+		t.Errorf("Expected the record of %s to be kept, was: %+v", issue1.Name, r)
+	}
+
+	if r, ok := s.Get("GitHub", issue2.Note); !ok || r.Name != renamed.Name {
+		t.Errorf("Expected the record of %s to be updated, was: %+v", issue2.Name, r)
+	}
+}
+
+// Tests that the external IDs of a source are listed in order
+func TestExternalIDs(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue2, issue1}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}, issue2.Key(): {ID: "a2"}}, synced)
+
+	if ids := s.ExternalIDs("GitHub"); len(ids) != 2 || ids[0] != issue1.Note || ids[1] != issue2.Note {
+		t.Errorf("Unexpected external IDs: %v", ids)
+	}
+
+	if ids := s.ExternalIDs("GitLab"); len(ids) != 0 {
+		t.Errorf("Expected no external IDs, was: %v", ids)
+	}
+}
+
+// Tests that fields changed in OmniFocus are kept while fields changed upstream are applied
+func TestRecordMerge(t *testing.T) {
+	s, _ := Load(t.TempDir())
+	s.Update("GitHub", []omnifocus.NewOmniFocusItem{issue1}, map[string]omnifocus.Item{issue1.Key(): {ID: "a1"}}, synced)
+	r, _ := s.Get("GitHub", issue1.Note)
+
+	upstream := issue1
+	upstream.Name = "[1] One, renamed upstream"
+
+	task := omnifocus.Item{ID: "a1", Name: issue1.Name, Note: issue1.Note + "\nmy notes", Tags: []string{"github", "urgent"}}
+	owner := func(field string) delta.Ownership { return delta.UpstreamWins }
+
+	u, changed := r.Merge(upstream, task, owner)
+	if !changed || u.Name == nil || *u.Name != upstream.Name {
+		t.Fatalf("Expected the name to be updated: %+v", u)
+	}
+
+	if u.Note != nil || u.Tags != nil || u.DueDateMS != nil {
+		t.Fatalf("Expected the local note and tags to be kept: %+v", u)
+	}
+
+	// When the note changed on both sides, appending keeps the local lines after the upstream ones
+	upstream.Note = "https://example.com/one"
+	owner = func(field string) delta.Ownership { return delta.Append }
+	u, _ = r.Merge(upstream, task, owner)
+	if u.Note == nil || *u.Note != "https://example.com/one\nmy notes" {
+		t.Fatalf("Unexpected note: %+v", u.Note)
+	}
+
+	task.Name = upstream.Name
+	task.Note = upstream.Note
+	if _, changed := r.Merge(upstream, task, owner); changed {
+		t.Fatalf("Expected no changes for a task that matches its item")
+	}
+
+	// Removing every tag upstream removes them from the task, rather than leaving one without a name
+	upstream.Tags = nil
+	owner = func(field string) delta.Ownership { return delta.UpstreamWins }
+	u, changed = r.Merge(upstream, task, owner)
+	if !changed || u.Tags == nil || len(u.Tags) != 0 {
+		t.Fatalf("Expected every tag to be removed: %#v", u.Tags)
+	}
+
+	if _, changed := (Record{}).Merge(upstream, omnifocus.Item{}, owner); changed {
+		t.Fatalf("Expected no changes without synced values")
+	}
+}
@@ -0,0 +1,102 @@
+// Package webhook accepts the webhooks of the sources in daemon mode and triggers a sync of the items they
+// concern, so changes show up in OmniFocus without waiting for the next poll.
+package webhook
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/daemon"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+// Prefix comes before the name of the source in the path its webhooks are posted to
+const Prefix = "/webhooks/"
+
+// maxBody caps the size of a delivery. GitHub's are at most 25 MB, but the ones carrying an item are far smaller.
+const maxBody = 5 << 20
+
+// Handler accepts the deliveries of the sources' webhooks at `/webhooks/<source name>`
+type Handler struct {
+	// Sources are the sources whose webhooks are accepted. Sources without a `Webhook` are ignored
+	Sources []source.Source
+	// Trigger syncs what a delivery concerns
+	Trigger func(target daemon.Target)
+}
+
+// MARK: Private helper methods
+// lookup returns the source with a webhook whose path is given
+func (h *Handler) lookup(p string) (source.Source, bool) {
+	name, err := url.PathUnescape(strings.TrimPrefix(p, Prefix))
+	if err != nil || !strings.HasPrefix(p, Prefix) {
+		return source.Source{}, false
+	}
+
+	for _, src := range h.Sources {
+		if src.Name == name && src.Webhook != nil {
+			return src, true
+		}
+	}
+
+	return source.Source{}, false
+}
+
+// MARK: Public methods
+// Path returns the path the webhooks of the named source are posted to
+func Path(name string) string {
+	return Prefix + url.PathEscape(name)
+}
+
+// ServeHTTP verifies the delivery and triggers a sync of the item it carries, or of its whole source. Signed
+// deliveries that don't concern any item, like GitHub's ping, are accepted without a sync.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	src, ok := h.lookup(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := src.VerifyWebhook(r.Header, body); err != nil {
+		log.Printf("[webhook] Rejecting a delivery for %s: %s", src.Name, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	records, sync, err := src.ParseWebhook(r.Header, body)
+	if err != nil {
+		log.Printf("[webhook] Rejecting a delivery for %s: %s", src.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !sync {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if records == nil {
+		log.Printf("[webhook] Syncing %s", src.Name)
+	} else {
+		for url := range records {
+			log.Printf("[webhook] Syncing %s of %s", url, src.Name)
+		}
+	}
+
+	h.Trigger(daemon.Target{Name: src.Name, Records: records})
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/trevorpiltch/omnifocus-sync/internal/daemon"
+	"github.com/trevorpiltch/omnifocus-sync/internal/source"
+)
+
+const secret = "It's a Secret to Everybody"
+
+// sources are configured like they are polled, with a webhook each
+var sources = []source.Source{
+	{
+		Name:     "GitHub Issues",
+		Response: source.Response{Title: "title", URL: "html_url", Number: "number", State: source.State{Field: "state", Open: []string{"open"}, Reason: "state_reason"}},
+		Webhook:  &source.Webhook{Provider: source.ProviderGitHub, Secret: secret},
+	},
+	{
+		// Polled issues link to their `web_url`, which the issues of webhooks don't have
+		Name:     "GitLab",
+		Response: source.Response{Title: "title", URL: "web_url", Number: "iid"},
+		Webhook:  &source.Webhook{Provider: source.ProviderGitLab, Secret: secret},
+	},
+	{
+		Name:     "Linear",
+		Response: source.Response{Title: "title", URL: "url", Number: "number"},
+		Webhook:  &source.Webhook{Provider: source.ProviderLinear, Secret: secret},
+	},
+	{
+		Name:     "Shortcut",
+		Response: source.Response{DataField: "data", Title: "name", URL: "app_url", Number: "id"},
+		Webhook:  &source.Webhook{Provider: source.ProviderShortcut, Secret: secret},
+	},
+	{
+		Name:     "Jira",
+		Response: source.Response{Title: "key", URL: "self", Number: "id"},
+		Webhook:  &source.Webhook{Provider: source.ProviderJira, Secret: secret},
+	},
+	{
+		Name:     "Polled",
+		Response: source.Response{Title: "title", URL: "html_url", Number: "number"},
+	},
+}
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// MARK: Helpers
+// sample returns the sample payload in testdata
+func sample(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(path.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read the sample %s: %s", name, err)
+	}
+
+	return data
+}
+
+// record returns the object in the field of the sample payload
+func record(t *testing.T, name, field string) map[string]interface{} {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(sample(t, name), &payload); err != nil {
+		t.Fatalf("Failed to parse the sample %s: %s", name, err)
+	}
+
+	return payload[field].(map[string]interface{})
+}
+
+// hexHMAC returns the hex HMAC-SHA256 of the body with the secret
+func hexHMAC(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// harness returns a server running the handler, and the targets it triggered
+func harness(t *testing.T) (*httptest.Server, *[]daemon.Target) {
+	var triggered []daemon.Target
+	h := &Handler{
+		Sources: sources,
+		Trigger: func(target daemon.Target) {
+			triggered = append(triggered, target)
+		},
+	}
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	return server, &triggered
+}
+
+// deliver posts the body to the path with the headers and returns the status of the response
+func deliver(t *testing.T, server *httptest.Server, method, p string, header map[string]string, body []byte) int {
+	req, err := http.NewRequest(method, server.URL+p, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create the request: %s", err)
+	}
+
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to deliver: %s", err)
+	}
+	res.Body.Close()
+
+	return res.StatusCode
+}
+
+// MARK: Handler tests
+// Tests that the sample payloads of every provider are verified and trigger a sync of their item, or of their
+// whole source when the item isn't in the shape of the polled ones
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   string
+		header   func(body []byte) map[string]string
+		expected *daemon.Target
+	}{
+		{
+			name:   "GitHub Issues",
+			sample: "github_issues.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "issues", "X-Hub-Signature-256": "sha256=" + hexHMAC(body)}
+			},
+			expected: &daemon.Target{Name: "GitHub Issues", Records: map[string]map[string]interface{}{
+				"https://github.com/trevorpiltch/omnifocus-sync/issues/257": record(t, "github_issues.json", "issue"),
+			}},
+		},
+		{
+			name:   "GitHub Issues",
+			sample: "github_ping.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + hexHMAC(body)}
+			},
+		},
+		{
+			name:   "GitLab",
+			sample: "gitlab_issue.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": secret}
+			},
+			expected: &daemon.Target{Name: "GitLab"},
+		},
+		{
+			name:   "Linear",
+			sample: "linear_issue.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"Linear-Event": "Issue", "Linear-Signature": hexHMAC(body)}
+			},
+			expected: &daemon.Target{Name: "Linear", Records: map[string]map[string]interface{}{
+				"https://linear.app/omnisync/issue/OMN-12/add-a-daemon": record(t, "linear_issue.json", "data"),
+			}},
+		},
+		{
+			name:   "Shortcut",
+			sample: "shortcut_story.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"Payload-Signature": hexHMAC(body)}
+			},
+			expected: &daemon.Target{Name: "Shortcut"},
+		},
+		{
+			// Jira's issue IDs are strings, so they can't be numbered like polled items
+			name:   "Jira",
+			sample: "jira_issue_updated.json",
+			header: func(body []byte) map[string]string {
+				return map[string]string{"X-Hub-Signature": "sha256=" + hexHMAC(body)}
+			},
+			expected: &daemon.Target{Name: "Jira"},
+		},
+	}
+
+	for _, test := range tests {
+		server, triggered := harness(t)
+		body := sample(t, test.sample)
+
+		expectedStatus := http.StatusAccepted
+		if test.expected == nil {
+			expectedStatus = http.StatusOK
+		}
+
+		if got := deliver(t, server, http.MethodPost, Path(test.name), test.header(body), body); got != expectedStatus {
+			t.Errorf("Expected %s to be answered with %d, was: %d", test.sample, expectedStatus, got)
+		}
+
+		var expected []daemon.Target
+		if test.expected != nil {
+			expected = []daemon.Target{*test.expected}
+		}
+
+		if !reflect.DeepEqual(*triggered, expected) {
+			t.Errorf("Unexpected targets of %s: %+v\nexpected: %+v", test.sample, *triggered, expected)
+		}
+	}
+}
+
+// Tests that deliveries that aren't signed, or not for a source with a webhook, never trigger a sync
+func TestReject(t *testing.T) {
+	body := sample(t, "github_issues.json")
+	signed := map[string]string{"X-Hub-Signature-256": "sha256=" + hexHMAC(body)}
+
+	tests := []struct {
+		description string
+		method      string
+		path        string
+		header      map[string]string
+		body        []byte
+		expected    int
+	}{
+		{"unsigned", http.MethodPost, Path("GitHub Issues"), nil, body, http.StatusUnauthorized},
+		{"signed with another secret", http.MethodPost, Path("GitHub Issues"), map[string]string{"X-Hub-Signature-256": "sha256=" + hexHMAC([]byte("other"))}, body, http.StatusUnauthorized},
+		{"signed for another provider", http.MethodPost, Path("Linear"), signed, body, http.StatusUnauthorized},
+		{"wrong GitLab token", http.MethodPost, Path("GitLab"), map[string]string{"X-Gitlab-Token": "guess"}, body, http.StatusUnauthorized},
+		{"not JSON", http.MethodPost, Path("GitHub Issues"), map[string]string{"X-Hub-Signature-256": "sha256=" + hexHMAC([]byte("<xml/>"))}, []byte("<xml/>"), http.StatusBadRequest},
+		{"unknown source", http.MethodPost, Path("Trello"), signed, body, http.StatusNotFound},
+		{"source without a webhook", http.MethodPost, Path("Polled"), signed, body, http.StatusNotFound},
+		{"not a delivery", http.MethodGet, Path("GitHub Issues"), signed, nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		server, triggered := harness(t)
+		if got := deliver(t, server, test.method, test.path, test.header, test.body); got != test.expected {
+			t.Errorf("Expected a delivery %s to be answered with %d, was: %d", test.description, test.expected, got)
+		}
+
+		if len(*triggered) != 0 {
+			t.Errorf("Expected a delivery %s not to trigger a sync, was: %+v", test.description, *triggered)
+		}
+	}
+}